@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configCmd groups the commands on the effective config
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "inspect the effective config",
+	Long: `inspect the effective config: the global .go-tomcat.yaml with the project-local ones merged over it,
+from the project_path of every app and from the current folder.`,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "print every effective config value, with --origin the file it comes from",
+	RunE:  execConfigShowCmd,
+	Args:  cobra.NoArgs,
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configShowCmd)
+
+	configShowCmd.Flags().Bool(originFlag, false, "print the file each value comes from")
+}
+
+func execConfigShowCmd(cmd *cobra.Command, args []string) error {
+	withOrigin, _ := cmd.Flags().GetBool(originFlag)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	keys := viper.AllKeys()
+	slices.Sort(keys)
+	for _, key := range keys {
+		value := fmt.Sprint(viper.Get(key))
+		if !withOrigin {
+			fmt.Fprintf(w, "%s\t%s\n", key, value)
+			continue
+		}
+		origin, ok := configOrigins[key]
+		if !ok {
+			origin = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", key, origin, value)
+	}
+	return w.Flush()
+}
@@ -27,7 +27,7 @@ func execInitCmd(cmd *cobra.Command, args []string) {
 		slog.Error("GO_TOMCAT_HOME not set")
 		return
 	}
-	slog.Info("GO_TOMCAT_HOME:", cmdBaseDir)
+	slog.Info("GO_TOMCAT_HOME", "path", cmdBaseDir)
 
 	_, err := os.Stat(CliBasePath)
 	if os.IsNotExist(err) {
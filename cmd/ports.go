@@ -0,0 +1,43 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nanaki-93/go-tomcat/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+// portsCmd represents the command to show the port allocation table
+var portsCmd = &cobra.Command{
+	Use:   "ports",
+	Short: "show the ports allocated to the tomcat servers",
+	Long:  `show the ports allocated to the tomcat servers, both running and reserved by a start in progress.`,
+	RunE:  execPortsCmd,
+	Args:  cobra.NoArgs,
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(portsCmd)
+}
+
+func execPortsCmd(cmd *cobra.Command, args []string) error {
+	tomcatProps, err := operation.LoadTomcatProps(CliBasePath)
+	if err != nil {
+		return fmt.Errorf("execPortsCmd : %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "APP\tSTATE\tMAIN\tSERVER\tDEBUG\tCONNECTOR\tREDIRECT")
+	for _, t := range tomcatProps.RunningTomcats {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			t.AppTomcatName, t.State, t.MainPort, t.ServerPort, t.DebugPort, t.ConnectorPort, t.RedirectPort)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// redeployCmd represents the command to deploy a new war in a running tomcat
+var redeployCmd = &cobra.Command{
+	Use:   "redeploy",
+	Short: "build the app and redeploy its war in the running tomcat server",
+	Long: `build the app and redeploy its war in the running tomcat server, without restarting it.
+The war in the deploy folder is replaced atomically, then it waits for tomcat to undeploy and deploy the app again,
+looking at the logs and at the exploded app folder.`,
+	RunE: execRedeployCmd,
+	Args: validateArgs(),
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(redeployCmd)
+
+	redeployCmd.Flags().BoolP(skipMavenFlag, "s", false, "if skipMaven is true, the build of the app is skipped")
+	redeployCmd.Flags().BoolP(offlineFlag, "o", false, "if offline is true, maven will run in offline mode")
+	redeployCmd.Flags().StringArray(mvnArgsFlag, nil, "extra argument passed as it is to the build tool, one per flag. Can be repeated, e.g. --mvn-args=-pl --mvn-args=core")
+	redeployCmd.Flags().DurationP(timeoutFlag, "t", 2*time.Minute, "time to wait for tomcat to redeploy the app")
+}
+
+func execRedeployCmd(cmd *cobra.Command, args []string) error {
+	appName := args[0]
+
+	tm, err := createTomcatManager(CliBasePath, appName)
+	if err != nil {
+		return fmt.Errorf("execRedeployCmd : %w", err)
+	}
+
+	if _, isRunning := tm.FindRunningTomcat(appName); !isRunning {
+		return fmt.Errorf("execRedeployCmd : the tomcat server of %s is not running, start it before redeploying", appName)
+	}
+
+	buildTool, err := tm.GetBuildTool()
+	if err != nil {
+		return fmt.Errorf("execRedeployCmd : %w", err)
+	}
+	buildStart := time.Now()
+	if err = buildApp(cmd, tm, buildTool); err != nil {
+		return fmt.Errorf("execRedeployCmd : %w", err)
+	}
+	buildDuration := time.Since(buildStart)
+
+	watch := tm.NewRedeployWatch()
+	if err = tm.ReplaceWar(buildTool); err != nil {
+		return fmt.Errorf("execRedeployCmd : %w", err)
+	}
+	slog.Info("War replaced, waiting for tomcat to redeploy it", "app", appName)
+
+	timeout, _ := cmd.Flags().GetDuration(timeoutFlag)
+	deployDuration, err := watch.Wait(timeout)
+	if err != nil {
+		return fmt.Errorf("execRedeployCmd : failed after %s: %w", deployDuration.Round(time.Millisecond), err)
+	}
+	fmt.Printf("%s redeployed: build %s, deploy %s\n", appName,
+		buildDuration.Round(time.Millisecond), deployDuration.Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/nanaki-93/go-tomcat/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+// renderCmd represents the command to render the tomcat config without starting it
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "render the tomcat config of an app without starting it",
+	Long: `render the tomcat config of an app without starting it. It creates the tomcat folder in a temporary
+or in the given directory, adds the app context and the db resources, renders all the files and prints
+the diff against the running instance of the app. The running apps and the ports are not touched.
+The temporary folder is removed at the end, unless --keep is given.`,
+	RunE: execRenderCmd,
+	Args: validateArgs(),
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringP(envFlag, "e", "", "env to render, one of the environments of the config")
+	_ = renderCmd.RegisterFlagCompletionFunc(envFlag, completeEnv)
+	renderCmd.Flags().StringP(acquirerFlag, "a", "", "acquirer to render")
+	renderCmd.Flags().StringArray(setFlag, nil, "template var as key=value, it overrides the vars of the config. Can be repeated")
+	renderCmd.Flags().String(dirFlag, "", "directory to render into, it must not exist or be empty. A temporary one by default")
+	renderCmd.Flags().Bool(keepFlag, false, "keep the temporary directory, to look at the rendered files")
+}
+
+func execRenderCmd(cmd *cobra.Command, args []string) error {
+	tm, err := createTomcatManager(CliBasePath, args[0])
+	if err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+	if err = setConfigFromFlags(cmd, tm); err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+
+	renderDir, _ := cmd.Flags().GetString(dirFlag)
+	keep, _ := cmd.Flags().GetBool(keepFlag)
+	if renderDir != "" {
+		if err = checkRenderDir(renderDir); err != nil {
+			return fmt.Errorf("execRenderCmd : %w", err)
+		}
+	} else {
+		if renderDir, err = os.MkdirTemp("", "gtom-render-"+args[0]+"-"); err != nil {
+			return fmt.Errorf("execRenderCmd : %w", err)
+		}
+		if !keep {
+			defer removeRenderDir(renderDir)
+		}
+	}
+	if renderDir, err = filepath.Abs(renderDir); err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+
+	runningPaths := tm.TomcatPaths
+	tm.TemplatePaths = runningPaths
+	tm.TomcatPaths = tm.TomcatPaths.WithHome(renderDir)
+
+	if err = tm.PreviewTomcatPorts(); err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+
+	// the rendered files of --dir and --keep are kept on failure, to look at them
+	templateData, renderedFiles, err := renderTomcatConfig(cmd, tm, operation.NewRollback())
+	if err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+	javaOpts, err := tm.RenderJavaOpts(templateData)
+	if err != nil {
+		return fmt.Errorf("execRenderCmd : %w", err)
+	}
+	slog.Info("Tomcat config rendered", "dir", renderDir, "tomcat", tm.TomcatProps.CurrentTomcat)
+	fmt.Println("JAVA_OPTS: " + operation.Mask(javaOpts))
+
+	if _, running := tm.FindRunningTomcat(args[0]); !running {
+		slog.Info("The app is not running, no diff to show", "app", args[0])
+		return nil
+	}
+	for _, renderedFile := range renderedFiles {
+		relPath, err := filepath.Rel(renderDir, renderedFile)
+		if err != nil {
+			return fmt.Errorf("execRenderCmd : %w", err)
+		}
+		diff, err := diffWithRunning(filepath.Join(runningPaths.HomeAppTomcat, relPath), renderedFile)
+		if err != nil {
+			return fmt.Errorf("execRenderCmd : %w", err)
+		}
+		fmt.Print(operation.Mask(diff))
+	}
+	return nil
+}
+
+// checkRenderDir fails when the directory exists and is not empty, the rendered files would mix with its content.
+func checkRenderDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("checkRenderDir : %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("checkRenderDir : %s is not empty", dir)
+	}
+	return nil
+}
+
+func removeRenderDir(dir string) {
+	if err := os.RemoveAll(dir); err != nil {
+		slog.Warn("Error removing the render folder", "dir", dir, "error", err)
+	}
+}
+
+// diffWithRunning returns the diff between the file of the running instance and the rendered one,
+// a missing running file is diffed as empty.
+func diffWithRunning(runningFile, renderedFile string) (string, error) {
+	rendered, err := os.ReadFile(renderedFile)
+	if err != nil {
+		return "", fmt.Errorf("diffWithRunning : %w", err)
+	}
+	fromName := runningFile
+	running, err := os.ReadFile(runningFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		fromName = "/dev/null"
+	} else if err != nil {
+		return "", fmt.Errorf("diffWithRunning : %w", err)
+	}
+	return operation.UnifiedDiff(fromName, renderedFile, string(running), string(rendered)), nil
+}
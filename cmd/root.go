@@ -19,13 +19,34 @@ import (
 var CliBasePath string
 var validAppList []string
 
-var validEnvList = []string{DevEnv, SitEnv, UatEnv, LocalEnv}
+// configOrigins is the file each config key comes from, see config show --origin.
+var configOrigins map[string]string
+
+var validEnvList []string
+
+// defaultEnvList is used when the config doesn't declare its environments.
+var defaultEnvList = []string{LocalEnv, DevEnv, SitEnv, UatEnv}
 
 const (
 	skipMavenFlag = "skipMaven"
 	offlineFlag   = "offline"
 	envFlag       = "env"
 	acquirerFlag  = "acquirer"
+	timeoutFlag   = "timeout"
+	cleanFlag     = "clean"
+	detachFlag    = "detach"
+	outputFlag    = "output"
+	mvnArgsFlag   = "mvn-args"
+	setFlag       = "set"
+	dirFlag       = "dir"
+	originFlag    = "origin"
+	watchFlag     = "watch"
+	deleteFlag    = "delete"
+	dryRunFlag    = "dry-run"
+	classesFlag   = "classes"
+	reloadFlag    = "reload"
+	keepFlag      = "keep"
+	defaultEnvKey = "default_env"
 	DevEnv        = "dev"
 	SitEnv        = "sit"
 	UatEnv        = "uat"
@@ -50,11 +71,18 @@ func Execute() {
 }
 
 func init() {
+	// resolved secrets are hidden in every log
+	slog.SetDefault(slog.New(operation.NewMaskingHandler(os.Stderr, slog.LevelInfo)))
 	cobra.OnInitialize(initConfig)
 	setCliBasePath()
 	rootCmd.AddCommand(completionCmd)
 }
 
+// completeEnv completes the env flag with the environments of the config.
+func completeEnv(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+	return validEnvList, cobra.ShellCompDirectiveNoFileComp
+}
+
 // redefine the completion command to make it hidden
 var completionCmd = &cobra.Command{
 	Use:    "completion",
@@ -80,9 +108,22 @@ func initConfig() {
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err != nil {
-		slog.Error("Error reading config file: %v\n", err)
+		slog.Error("Error reading config file", "error", err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		slog.Error("Error getting working dir", "error", err)
+	}
+	configOrigins, err = operation.MergeProjectConfigs(viper.GetViper(), cwd)
+	if err != nil {
+		slog.Error("Error merging the project config files", "error", err)
 	}
 
 	validAppList = viper.GetStringSlice("apps")
+	validEnvList = viper.GetStringSlice("environments")
+	if len(validEnvList) == 0 {
+		validEnvList = defaultEnvList
+	}
 
 }
@@ -0,0 +1,99 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/nanaki-93/go-tomcat/internal/operation"
+	"github.com/spf13/cobra"
+)
+
+// secretCmd groups the commands on the local encrypted secrets
+var secretCmd = &cobra.Command{
+	Use:   "secret",
+	Short: "manage the local encrypted secrets",
+	Long: `manage the local encrypted secrets, referenced as ${secret:name} in the tomcat files,
+.db-resources.yaml and java_opts. They are resolved at render time.`,
+}
+
+var secretSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "set a secret, the value is asked or read from stdin",
+	Long: `set a secret, the value is asked without echo, or read from the first line of stdin when it is not a terminal.
+It is never taken from the command line, to keep it out of the shell history.`,
+	RunE: execSecretSetCmd,
+	Args: cobra.ExactArgs(1),
+
+	SilenceUsage: true,
+}
+
+var secretGetCmd = &cobra.Command{
+	Use:   "get <name>",
+	Short: "print the value of a secret",
+	RunE:  execSecretGetCmd,
+	Args:  cobra.ExactArgs(1),
+
+	SilenceUsage: true,
+}
+
+var secretListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "list the names of the secrets",
+	RunE:    execSecretListCmd,
+	Args:    cobra.NoArgs,
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(secretCmd)
+	secretCmd.AddCommand(secretSetCmd, secretGetCmd, secretListCmd)
+}
+
+func execSecretSetCmd(cmd *cobra.Command, args []string) error {
+	store, err := operation.OpenSecretStore(CliBasePath)
+	if err != nil {
+		return fmt.Errorf("execSecretSetCmd : %w", err)
+	}
+
+	value, err := operation.PasswordPrompt(fmt.Sprintf("value of %s:", args[0]))
+	if err != nil {
+		return fmt.Errorf("execSecretSetCmd : %w", err)
+	}
+	if value == "" {
+		return fmt.Errorf("execSecretSetCmd : empty value for %s", args[0])
+	}
+
+	if err = store.Set(args[0], value); err != nil {
+		return fmt.Errorf("execSecretSetCmd : %w", err)
+	}
+	fmt.Printf("secret %s saved, use it as ${secret:%s}\n", args[0], args[0])
+	return nil
+}
+
+func execSecretGetCmd(cmd *cobra.Command, args []string) error {
+	store, err := operation.OpenSecretStore(CliBasePath)
+	if err != nil {
+		return fmt.Errorf("execSecretGetCmd : %w", err)
+	}
+	value, ok := store.Get(args[0])
+	if !ok {
+		return fmt.Errorf("execSecretGetCmd : secret %s not found", args[0])
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func execSecretListCmd(cmd *cobra.Command, args []string) error {
+	store, err := operation.OpenSecretStore(CliBasePath)
+	if err != nil {
+		return fmt.Errorf("execSecretListCmd : %w", err)
+	}
+	for _, name := range store.Names() {
+		fmt.Println(name)
+	}
+	return nil
+}
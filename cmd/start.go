@@ -6,9 +6,10 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"slices"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 
 	"github.com/nanaki-93/go-tomcat/internal/model"
 	"github.com/nanaki-93/go-tomcat/internal/operation"
@@ -21,17 +22,23 @@ var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "start the tomcat server",
 	Long:  `start the tomcat server. It will create a new tomcat instance with the specified app and env.`,
-	Run:   execStartCmd,
+	RunE:  execStartCmd,
 	Args:  validateArgs(),
+
+	SilenceUsage: true,
 }
 
 func init() {
 	rootCmd.AddCommand(startCmd)
 
-	startCmd.Flags().BoolP(skipMavenFlag, "s", false, "if skipMaven is true, maven task is skipped")
+	startCmd.Flags().BoolP(skipMavenFlag, "s", false, "if skipMaven is true, the build of the app is skipped")
 	startCmd.Flags().BoolP(offlineFlag, "o", false, "if offline is true, maven will run in offline mode")
-	startCmd.Flags().StringP(envFlag, "e", "", "env to start")
+	startCmd.Flags().StringP(envFlag, "e", "", "env to start, one of the environments of the config")
+	_ = startCmd.RegisterFlagCompletionFunc(envFlag, completeEnv)
 	startCmd.Flags().StringP(acquirerFlag, "a", "", "acquirer to start")
+	startCmd.Flags().StringArray(mvnArgsFlag, nil, "extra argument passed as it is to the build tool, one per flag. Can be repeated, e.g. --mvn-args=-pl --mvn-args=core")
+	startCmd.Flags().StringArray(setFlag, nil, "template var as key=value, it overrides the vars of the config. Can be repeated")
+	startCmd.Flags().BoolP(detachFlag, "d", false, "if detach is true, tomcat runs in background and logs to the app logs folder")
 }
 
 func validateArgs() func(cmd *cobra.Command, args []string) error {
@@ -47,129 +54,203 @@ func validateArgs() func(cmd *cobra.Command, args []string) error {
 	}
 }
 
-func execStartCmd(cmd *cobra.Command, args []string) {
-	var err error
-
+func execStartCmd(cmd *cobra.Command, args []string) error {
 	tm, err := createTomcatManager(CliBasePath, args[0])
-	operation.CheckErr(err)
+	if err != nil {
+		return fmt.Errorf("execStartCmd : %w", err)
+	}
+
+	if err = setConfigFromFlags(cmd, tm); err != nil {
+		return fmt.Errorf("execStartCmd : %w", err)
+	}
 
-	tm.TomcatConfig.EnvToStart = setEnvToStart(cmd)
+	rollback := operation.NewRollback()
+	checkInterrupt(rollback)
 
-	checkInterrupt(tm)
+	if err = startTomcat(cmd, tm, rollback); err != nil {
+		rollback.Run()
+		return fmt.Errorf("execStartCmd : %w", err)
+	}
+	return nil
+}
 
-	err = tm.RemoveFromRunningAppsConfig()
-	operation.CheckErr(err)
+// startTomcat runs the start pipeline, every completed step that leaves something behind
+// registers its undo in the rollback.
+func startTomcat(cmd *cobra.Command, tm *operation.TomcatManager, rollback *operation.Rollback) error {
+	err := tm.RemoveFromRunningAppsConfig()
+	if err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
 	//Clean Tomcat folders if exists and they are not running
-	err = tm.RemoveUnusedTomcatFolder()
-	operation.CheckErr(err)
+	if err = tm.RemoveUnusedTomcatFolder(); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	if err = tm.ReserveTomcatPorts(); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+	rollback.Add("port reservation", tm.RemoveCurrentFromRunningAppsConfig)
+
+	templateData, _, err := renderTomcatConfig(cmd, tm, rollback)
+	if err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
 
-	tm.SetTomcatPorts()
+	if err = printStartBanner(tm, templateData); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
 
-	err = tm.CreateTomcat()
-	operation.CheckErr(err)
+	buildTool, err := tm.GetBuildTool()
+	if err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	if err = buildApp(cmd, tm, buildTool); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	if err = tm.CopyAppToTomcat(buildTool); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	if err = tm.SetSystemEnv(); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	if err = tm.SetJavaOpts(templateData); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+
+	detach, _ := cmd.Flags().GetBool(detachFlag)
+	if err = tm.RunTomcat(detach, rollback); err != nil {
+		return fmt.Errorf("startTomcat : %w", err)
+	}
+	return nil
+}
+
+// renderTomcatConfig creates the tomcat folder of the app and renders its config files,
+// registering the undo of the created files in the rollback. It returns the rendered files.
+func renderTomcatConfig(cmd *cobra.Command, tm *operation.TomcatManager, rollback *operation.Rollback) (model.TemplateData, []string, error) {
+	if err := tm.CreateTomcat(); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
+	rollback.Add("tomcat folder", func() error {
+		return os.RemoveAll(tm.TomcatPaths.HomeAppTomcat)
+	})
 
 	dbResources, err := tm.GetDbResources()
-	operation.CheckErr(err)
+	if err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 
 	dbContext, err := tm.GetDbContext()
-	operation.CheckErr(err)
+	if err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 
-	err = tm.CopyAppContext()
-	operation.CheckErr(err)
+	if err = tm.CopyAppContext(); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
+	appContextFile := tm.AppContextFile()
+	rollback.Add("app context", func() error {
+		return os.RemoveAll(appContextFile)
+	})
 
 	fileListToAdd := []string{
 		tm.TomcatPaths.ServerXml,
 		tm.TomcatPaths.ContextXml,
-		filepath.Join(tm.TomcatPaths.CatalinaLocalhost, tm.TomcatConfig.AppConfig.ContextFileName+".xml"),
+		appContextFile,
 	}
 
 	acquirer, _ := cmd.Flags().GetString(acquirerFlag)
 	acquirerToSet, err := tm.SetAcquirer(acquirer)
-	operation.CheckErr(err)
-
-	keysToReplace := map[string]string{
-		"{{catalina_home}}":      tm.TomcatPaths.HomeAppTomcat,
-		"{{context_file_name}}":  tm.TomcatConfig.AppConfig.ContextFileName,
-		"{{debug_port}}":         fmt.Sprint(tm.TomcatProps.CurrentTomcat.DebugPort),
-		"{{project_path}}":       tm.TomcatConfig.AppConfig.ProjectPath,
-		"{{tomcat_deploy_path}}": tm.TomcatPaths.Deploy,
-		"{{war_name}}":           tm.TomcatConfig.AppConfig.WarName,
-		"{{main_port}}":          fmt.Sprint(tm.TomcatProps.CurrentTomcat.MainPort),
-		"{{server_port}}":        fmt.Sprint(tm.TomcatProps.CurrentTomcat.ServerPort),
-		"{{connector_port}}":     fmt.Sprint(tm.TomcatProps.CurrentTomcat.ConnectorPort),
-		"{{redirect_port}}":      fmt.Sprint(tm.TomcatProps.CurrentTomcat.RedirectPort),
-		"{{db_resources}}":       dbResources,
-		"{{db_context}}":         dbContext,
+	if err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
 	}
 
-	if acquirerToSet != "" {
-		keysToReplace["{{acquirer}}"] = acquirerToSet
-	}
+	templateData := tm.NewTemplateData(dbResources, dbContext, acquirerToSet)
 
 	appsConfigFile, err := tm.AddAppsConfigProps()
-	operation.CheckErr(err)
+	if err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 	if len(appsConfigFile) > 0 {
 		fileListToAdd = append(fileListToAdd, appsConfigFile)
 	}
 
 	indexPageFile, err := tm.CopyIndexPage()
-	operation.CheckErr(err)
+	if err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 	if len(indexPageFile) > 0 {
 		fileListToAdd = append(fileListToAdd, indexPageFile)
 	}
 
 	slog.Info("all the resources are added")
 
-	err = operation.UpdatePropsInFiles(fileListToAdd, keysToReplace)
-	if err != nil {
-		slog.Error("error replacing in file", "error", err)
-		return
+	if err = tm.CheckTemplates(fileListToAdd, templateData); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
 	}
-	slog.Info("all the resources are replaced")
 
-	checkedKeys := make([]string, 0)
-	for k := range keysToReplace {
-		checkedKeys = append(checkedKeys, k)
+	if err = operation.RenderFiles(fileListToAdd, templateData); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : error rendering file: %w", err)
 	}
-	err = operation.CheckInFile(fileListToAdd, checkedKeys)
-	if err != nil {
-		slog.Error("something went wrong in the replacement process", "error", err)
-		return
+	slog.Info("all the resources are rendered")
+
+	if err = tm.CheckRendered(fileListToAdd, templateData); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : something went wrong in the rendering process: %w", err)
 	}
-	err = buildWithMaven(cmd, tm)
-	operation.CheckErr(err)
 
-	err = tm.CopyAppToTomcat()
-	operation.CheckErr(err)
+	if err = tm.ApplyXmlConfig(templateData); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 
-	err = tm.SetSystemEnv()
-	operation.CheckErr(err)
+	if err = tm.Secrets.ResolveFiles(fileListToAdd); err != nil {
+		return model.TemplateData{}, nil, fmt.Errorf("renderTomcatConfig : %w", err)
+	}
 
-	err = tm.SetJavaOpts(keysToReplace)
-	operation.CheckErr(err)
+	return templateData, fileListToAdd, nil
+}
 
-	err = tm.RunTomcat()
-	operation.CheckErr(err)
+// printStartBanner shows what the app is about to be started against, before the build.
+func printStartBanner(tm *operation.TomcatManager, data model.TemplateData) error {
+	dbUrls, err := tm.DbUrls(data)
+	if err != nil {
+		return fmt.Errorf("printStartBanner : %w", err)
+	}
 
+	const line = "================================================================"
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, line)
+	fmt.Fprintf(w, "  APP\t%s\n", data.AppName)
+	fmt.Fprintf(w, "  ENV\t%s\n", strings.ToUpper(data.Env))
+	if acquirer := tm.TomcatProps.CurrentTomcat.Acquirer; acquirer != "" {
+		fmt.Fprintf(w, "  ACQUIRER\t%s (%s)\n", acquirer, data.Acquirer)
+	}
+	if len(dbUrls) == 0 {
+		fmt.Fprintln(w, "  DB\tnone")
+	}
+	for _, name := range operation.GetOrderedKeys(dbUrls) {
+		fmt.Fprintf(w, "  DB %s\t%s\n", name, dbUrls[name])
+	}
+	fmt.Fprintln(w, line)
+	return w.Flush()
 }
 
-func checkInterrupt(tomcatService *operation.TomcatManager) {
+// checkInterrupt runs the rollback on ctrl+c: before catalina is started it undoes the start,
+// after it only removes the app from the running apps.
+func checkInterrupt(rollback *operation.Rollback) {
 	// Create a channel to receive OS signals
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
 		<-sigs
-		cleanupFunction(tomcatService)
+		slog.Info("CLEANING UP RESOURCES...")
+		rollback.Run()
 		os.Exit(0)
 	}()
 }
-func cleanupFunction(ts *operation.TomcatManager) {
-	slog.Info("CLEANING UP RESOURCES...")
-
-	err := ts.RemoveCurrentFromRunningAppsConfig()
-	operation.CheckErr(err)
-}
 
 func createTomcatManager(basePath, appName string) (*operation.TomcatManager, error) {
 
@@ -193,34 +274,82 @@ func createTomcatManager(basePath, appName string) (*operation.TomcatManager, er
 
 }
 
-func buildWithMaven(cmd *cobra.Command, ts *operation.TomcatManager) error {
+func buildApp(cmd *cobra.Command, ts *operation.TomcatManager, buildTool operation.BuildTool) error {
 
 	offline, _ := cmd.Flags().GetBool(offlineFlag)
 
 	skipMaven, _ := cmd.Flags().GetBool(skipMavenFlag)
 	if skipMaven {
-		slog.Info("Skipping Maven build")
+		slog.Info("Skipping build", "buildTool", buildTool.Name())
 		return nil
 	}
-	err := ts.SetSystemEnv()
 
-	stCmd := ts.GetMvnCommand(offline)
+	mvnArgs, _ := cmd.Flags().GetStringArray(mvnArgsFlag)
+	stCmd := buildTool.BuildCommand(offline, mvnArgs)
+	if stCmd == nil {
+		slog.Info("Nothing to build", "buildTool", buildTool.Name())
+		return nil
+	}
 
-	operation.CheckErr(err)
+	if err := ts.SetSystemEnv(); err != nil {
+		return fmt.Errorf("buildApp : %w", err)
+	}
 
 	operation.PrintCmd(stCmd)
 	if err := stCmd.Run(); err != nil {
-		return fmt.Errorf("buildWithMaven : %w", err)
+		return fmt.Errorf("buildApp : %w", err)
 	}
 	return nil
 }
 
-func setEnvToStart(cmd *cobra.Command) string {
+// setEnvToStart returns the env flag or, when missing, the default_env of the config. An unknown env is an error.
+func setEnvToStart(cmd *cobra.Command) (string, error) {
 	envToStart, _ := cmd.Flags().GetString(envFlag)
 
-	if slices.Contains(validEnvList, envToStart) {
-		return envToStart
+	if envToStart == "" {
+		envToStart = viper.GetString(defaultEnvKey)
+		if envToStart == "" {
+			return "", fmt.Errorf("setEnvToStart : no env, use --%s or set %s in .go-tomcat.yaml. Valid envs: %v",
+				envFlag, defaultEnvKey, validEnvList)
+		}
+		slog.Info("no env flag, using the default env", "env", envToStart)
+	}
+
+	if !slices.Contains(validEnvList, envToStart) {
+		if suggestion := operation.ClosestKey(envToStart, validEnvList); suggestion != "" {
+			return "", fmt.Errorf("setEnvToStart : env not valid: %s, did you mean %s? Valid envs: %v", envToStart, suggestion, validEnvList)
+		}
+		return "", fmt.Errorf("setEnvToStart : env not valid: %s. Valid envs: %v", envToStart, validEnvList)
 	}
-	slog.Warn("no env flag, using Dev env")
-	return DevEnv
+	return envToStart, nil
+}
+
+// parseSetVars parses the key=value pairs of the set flag.
+func parseSetVars(setVars []string) (map[string]string, error) {
+	vars := make(map[string]string, len(setVars))
+	for _, setVar := range setVars {
+		key, value, found := strings.Cut(setVar, "=")
+		if !found || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("parseSetVars : var not valid: %s, use key=value", setVar)
+		}
+		vars[strings.ToLower(strings.TrimSpace(key))] = value
+	}
+	return vars, nil
+}
+
+// setConfigFromFlags sets the env to start and the vars of the set flag.
+func setConfigFromFlags(cmd *cobra.Command, tm *operation.TomcatManager) error {
+	envToStart, err := setEnvToStart(cmd)
+	if err != nil {
+		return fmt.Errorf("setConfigFromFlags : %w", err)
+	}
+	tm.TomcatConfig.EnvToStart = envToStart
+
+	setVars, _ := cmd.Flags().GetStringArray(setFlag)
+	vars, err := parseSetVars(setVars)
+	if err != nil {
+		return fmt.Errorf("setConfigFromFlags : %w", err)
+	}
+	tm.TomcatConfig.SetVars = vars
+	return nil
 }
@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+	"github.com/nanaki-93/go-tomcat/internal/operation"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	yamlOutput  = "yaml"
+)
+
+// statusCmd represents the command to list the managed tomcats
+var statusCmd = &cobra.Command{
+	Use:     "status",
+	Aliases: []string{"ps"},
+	Short:   "list all the managed tomcat servers",
+	Long: `list all the managed tomcat servers saved in the running apps.
+It checks the pid, the server port and the main port of each app and marks the stale entries.`,
+	RunE: execStatusCmd,
+	Args: cobra.NoArgs,
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+	statusCmd.Flags().StringP(outputFlag, "o", tableOutput, "output format: table, json or yaml")
+}
+
+func execStatusCmd(cmd *cobra.Command, args []string) error {
+	tomcatProps, err := operation.LoadTomcatProps(CliBasePath)
+	if err != nil {
+		return fmt.Errorf("execStatusCmd : %w", err)
+	}
+
+	statusList := operation.GetTomcatStatusList(tomcatProps)
+
+	output, _ := cmd.Flags().GetString(outputFlag)
+	if err = printStatusList(statusList, output); err != nil {
+		return fmt.Errorf("execStatusCmd : %w", err)
+	}
+	return nil
+}
+
+func printStatusList(statusList []model.TomcatStatus, output string) error {
+	switch output {
+	case jsonOutput:
+		data, err := json.MarshalIndent(statusList, "", "  ")
+		if err != nil {
+			return fmt.Errorf("printStatusList : %w", err)
+		}
+		fmt.Println(string(data))
+	case yamlOutput:
+		data, err := yaml.Marshal(statusList)
+		if err != nil {
+			return fmt.Errorf("printStatusList : %w", err)
+		}
+		fmt.Print(string(data))
+	case tableOutput:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "APP\tSTATUS\tMAIN\tSERVER\tDEBUG\tCONNECTOR\tREDIRECT\tENV\tACQUIRER\tPID\tUPTIME\tDEPLOY PATH")
+		for _, s := range statusList {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\t%s\t%d\t%s\t%s\n",
+				s.AppTomcatName, s.Status, s.MainPort, s.ServerPort, s.DebugPort, s.ConnectorPort, s.RedirectPort,
+				s.Env, s.Acquirer, s.Pid, s.Uptime, s.DeployPath)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("printStatusList : output format not valid: %s. select one from the following list: %v",
+			output, []string{tableOutput, jsonOutput, yamlOutput})
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright © 2025 Marco Andreose <andreose.marco93@gmail.com>
+*/
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stopCmd represents the command to stop a running tomcat
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "stop a running tomcat server",
+	Long: `stop a running tomcat server. It sends the shutdown command to the server port of the app,
+kills the process if it is still alive after the timeout and removes it from the running apps.`,
+	RunE: execStopCmd,
+	Args: validateArgs(),
+
+	SilenceUsage: true,
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+
+	stopCmd.Flags().DurationP(timeoutFlag, "t", 30*time.Second, "time to wait for a graceful shutdown before killing the process")
+	stopCmd.Flags().BoolP(cleanFlag, "c", false, "if clean is true, the tomcat folder of the app is deleted")
+}
+
+func execStopCmd(cmd *cobra.Command, args []string) error {
+	tm, err := createTomcatManager(CliBasePath, args[0])
+	if err != nil {
+		return fmt.Errorf("execStopCmd : %w", err)
+	}
+
+	timeout, _ := cmd.Flags().GetDuration(timeoutFlag)
+	if err = tm.StopTomcat(timeout); err != nil {
+		return fmt.Errorf("execStopCmd : %w", err)
+	}
+
+	if err = tm.RemoveCurrentFromRunningAppsConfig(); err != nil {
+		return fmt.Errorf("execStopCmd : %w", err)
+	}
+
+	clean, _ := cmd.Flags().GetBool(cleanFlag)
+	if clean {
+		if err = os.RemoveAll(tm.TomcatPaths.HomeAppTomcat); err != nil {
+			return fmt.Errorf("execStopCmd : error removing the tomcat folder: %w", err)
+		}
+		slog.Info("Tomcat folder removed", "folder", tm.TomcatPaths.HomeAppTomcat)
+	}
+	return nil
+}
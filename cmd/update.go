@@ -5,115 +5,123 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/nanaki-93/go-tomcat/internal/operation"
-	"github.com/spf13/cobra"
-	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
-	"strings"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
-)
 
-var jspFolderSuffix = filepath.Join("src", "main", "webapp")
+	"github.com/nanaki-93/go-tomcat/internal/operation"
+	"github.com/spf13/cobra"
+)
 
-// startCmd represents the master command
+// updateCmd represents the command to sync the webapp files in the running tomcat
 var updateCmd = &cobra.Command{
 	Use:   "update",
-	Short: "update the app's jsp in the tomcat server",
-	Long:  `update the app's jsp in the tomcat server. It will copy all the jsp files from the source folder to the destination folder in the tomcat server.`,
-	Run:   execUpdateCmd,
-	Args:  validateArgs(),
+	Short: "update the app's webapp files in the tomcat server",
+	Long: `update the app's webapp files in the tomcat server. It copies the whole src/main/webapp tree (jsp, jspf, css, js,
+images, WEB-INF tag files...) to the exploded app folder in the tomcat server, keeping the folder structure.
+The files can be filtered per app with the update_include and update_exclude globs.
+Only the files changed since the last update are copied, --delete removes the synced files deleted
+from the source and --dry-run shows what would be done.
+With --classes the compiled classes and resources of target/classes are synced into WEB-INF/classes,
+--reload then makes tomcat reload the app by touching WEB-INF/web.xml, keeping the exploded folder.
+With --watch it keeps syncing the changed files until ctrl+c or until the tomcat is stopped.`,
+	RunE: execUpdateCmd,
+	Args: validateArgs(),
+
+	SilenceUsage: true,
 }
 
 func init() {
 	rootCmd.AddCommand(updateCmd)
-}
 
-func execUpdateCmd(cmd *cobra.Command, args []string) {
-	const poolSize = 100
-	pool := make(chan struct{}, poolSize)
-	wg := new(sync.WaitGroup)
+	updateCmd.Flags().BoolP(watchFlag, "w", false, "if watch is true, the changed files are synced until ctrl+c or the tomcat stops")
+	updateCmd.Flags().Bool(deleteFlag, false, "if delete is true, the files synced before and deleted from the source are deleted from the tomcat")
+	updateCmd.Flags().Bool(classesFlag, false, "if classes is true, target/classes is synced into WEB-INF/classes instead of the webapp files")
+	updateCmd.Flags().Bool(reloadFlag, false, "if reload is true, the app context is reloaded after a sync that changed something. The app needs a WEB-INF/web.xml")
+	updateCmd.Flags().Bool(dryRunFlag, false, "if dry-run is true, it shows what would be copied and deleted without touching the tomcat")
+}
 
+func execUpdateCmd(cmd *cobra.Command, args []string) error {
 	appName := args[0]
 
 	tm, err := createTomcatManager(CliBasePath, appName)
-	operation.CheckErr(err)
+	if err != nil {
+		return fmt.Errorf("execUpdateCmd : %w", err)
+	}
 
-	isRunning := false
-	for _, tomcat := range tm.TomcatProps.RunningTomcats {
-		if tomcat.AppTomcatName == appName {
-			isRunning = true
-			break
-		}
+	if _, isRunning := tm.FindRunningTomcat(appName); !isRunning {
+		return fmt.Errorf("execUpdateCmd : the tomcat server of %s is not running, start it before updating the files", appName)
 	}
 
-	if !isRunning {
-		slog.Error("the tomcat server is not running", "appName", appName)
-		slog.Error("you need to start the tomcat server before updating the jsp files")
-		return
+	dirs := tm.WebappSync()
+	if classes, _ := cmd.Flags().GetBool(classesFlag); classes {
+		dirs = tm.ClassesSync()
+	}
+	files, err := dirs.Files()
+	if err != nil {
+		return fmt.Errorf("execUpdateCmd : %w", err)
+	}
+	if len(files) == 0 {
+		slog.Warn("No files found to update in the source path", "sourcePath", dirs.Source)
 	}
-	sourcePath := filepath.Join(tm.TomcatConfig.AppConfig.ProjectPath, jspFolderSuffix)
-	destPath := filepath.Join(tm.TomcatPaths.HomeAppTomcat, "webapps", tm.TomcatConfig.AppConfig.ContextFileName)
 
-	sourceFileList, err := os.ReadDir(sourcePath)
-	startTime := time.Now()
-	slog.Warn("Start at: time", "time", startTime.Format(time.RFC3339))
-	counter := 0
-	for _, file := range sourceFileList {
-		if file.IsDir() || !strings.Contains(file.Name(), ".jsp") {
-			continue
+	reload, _ := cmd.Flags().GetBool(reloadFlag)
+	if reload {
+		if err = tm.CheckReload(); err != nil {
+			return fmt.Errorf("execUpdateCmd : %w", err)
 		}
-		sourceFilePath := filepath.Join(sourcePath, file.Name())
-		destFilePath := filepath.Join(destPath, file.Name())
-		counter++
-		pool <- struct{}{} // acquire slot
-		wg.Add(1)
-		go copyRoutine(wg, pool, sourceFilePath, destFilePath)
 	}
-	wg.Wait()
-	endTime := time.Now()
-	slog.Info("End at: time", "time", endTime.Format(time.RFC3339))
-	slog.Info("Duration: time", "time", endTime.Sub(startTime).String())
-	if counter == 0 {
-		slog.Warn("No jsp files found to update in the source path", "sourcePath", sourcePath)
-	} else {
-		slog.Info("Total files copied: ", "files", counter)
-		slog.Info("all the jsp files are updated in the tomcat server")
+
+	startTime := time.Now()
+	withDelete, _ := cmd.Flags().GetBool(deleteFlag)
+	plan, err := operation.PlanUpdate(dirs, files, withDelete)
+	if err != nil {
+		return fmt.Errorf("execUpdateCmd : %w", err)
 	}
 
-	wg.Wait()
-}
-func copyRoutine(wg *sync.WaitGroup, pool chan struct{}, src, dst string) {
-	defer wg.Done()
-	defer func() { <-pool }() // release slot
-	err := copyFileContents(src, dst)
-	operation.CheckErr(err)
-}
+	if dryRun, _ := cmd.Flags().GetBool(dryRunFlag); dryRun {
+		printUpdatePlan(plan)
+		fmt.Println("dry run:", plan)
+		return nil
+	}
 
-// copyFileContents copies the contents of the file named src to the file named
-// by dst. The file will be created if it does not already exist. If the
-// destination file exists, all it's contents will be replaced by the contents
-// of the source file.
-func copyFileContents(src, dst string) error {
-	in, err := os.Open(src)
-	if err != nil {
-		return fmt.Errorf("copyFileContents : %w", err)
+	if err = operation.ApplyUpdate(plan); err != nil {
+		return fmt.Errorf("execUpdateCmd : %w", err)
 	}
-	defer in.Close()
-	out, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("copyFileContents : %w", err)
+	fmt.Println(plan)
+	slog.Info("the files are updated in the tomcat server", "duration", time.Since(startTime).String())
+
+	var afterSync func() error
+	if reload {
+		afterSync = tm.ReloadAppContext
+		if plan.HasChanges() {
+			if err = tm.ReloadAppContext(); err != nil {
+				return fmt.Errorf("execUpdateCmd : %w", err)
+			}
+		}
 	}
-	defer func() {
-		cerr := out.Close()
-		if err == nil {
-			err = cerr
+
+	if watch, _ := cmd.Flags().GetBool(watchFlag); watch {
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err = tm.Watch(ctx, dirs, operation.WatchDebounce, afterSync); err != nil {
+			return fmt.Errorf("execUpdateCmd : %w", err)
 		}
-	}()
-	if _, err = io.Copy(out, in); err != nil {
-		return fmt.Errorf("copyFileContents : %w", err)
 	}
-	return out.Sync()
+	return nil
+}
+
+// printUpdatePlan lists the files to copy and delete: + added, ~ changed, - deleted.
+func printUpdatePlan(plan *operation.UpdatePlan) {
+	for _, rel := range plan.Added {
+		fmt.Println("+", rel)
+	}
+	for _, rel := range plan.Changed {
+		fmt.Println("~", rel)
+	}
+	for _, rel := range plan.Deleted {
+		fmt.Println("-", rel)
+	}
 }
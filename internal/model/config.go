@@ -3,6 +3,9 @@ package model
 import (
 	"fmt"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -14,27 +17,109 @@ type TomcatProps struct {
 	CurrentTomcat  Tomcat   `yaml:"-"`
 }
 type Tomcat struct {
-	AppTomcatName string `yaml:"app_tomcat_name"`
-	MainPort      int    `yaml:"main_port"`
-	ServerPort    int    `yaml:"server_port"`
-	DebugPort     int    `yaml:"debug_port"`
-	ConnectorPort int    `yaml:"-"`
-	RedirectPort  int    `yaml:"-"`
+	AppTomcatName string    `yaml:"app_tomcat_name"`
+	MainPort      int       `yaml:"main_port"`
+	ServerPort    int       `yaml:"server_port"`
+	DebugPort     int       `yaml:"debug_port"`
+	Pid           int       `yaml:"pid,omitempty"`
+	Env           string    `yaml:"env,omitempty"`
+	Acquirer      string    `yaml:"acquirer,omitempty"`
+	DeployPath    string    `yaml:"deploy_path,omitempty"`
+	StartedAt     time.Time `yaml:"started_at,omitempty"`
+	ConnectorPort int       `yaml:"connector_port"`
+	RedirectPort  int       `yaml:"redirect_port"`
+	State         string    `yaml:"state,omitempty"`
+	ReservedBy    int       `yaml:"reserved_by,omitempty"`
+}
+
+// Ports returns all the ports allocated to the tomcat.
+func (t Tomcat) Ports() []int {
+	return []int{t.MainPort, t.ServerPort, t.DebugPort, t.ConnectorPort, t.RedirectPort}
+}
+
+// Tomcat states, a reserved tomcat holds its ports while the cli with pid ReservedBy is building it.
+const (
+	StateReserved = "reserved"
+	StateRunning  = "running"
+)
+
+const (
+	StatusRunning  = "running"
+	StatusStarting = "starting"
+	StatusStale    = "stale"
+	StatusReserved = "reserved"
+)
+
+// TomcatStatus is the liveness view of a running tomcat entry, used by the status command.
+type TomcatStatus struct {
+	AppTomcatName  string `json:"app_tomcat_name" yaml:"app_tomcat_name"`
+	Status         string `json:"status" yaml:"status"`
+	MainPort       int    `json:"main_port" yaml:"main_port"`
+	ServerPort     int    `json:"server_port" yaml:"server_port"`
+	DebugPort      int    `json:"debug_port" yaml:"debug_port"`
+	ConnectorPort  int    `json:"connector_port" yaml:"connector_port"`
+	RedirectPort   int    `json:"redirect_port" yaml:"redirect_port"`
+	Env            string `json:"env" yaml:"env"`
+	Acquirer       string `json:"acquirer" yaml:"acquirer"`
+	Pid            int    `json:"pid" yaml:"pid"`
+	PidAlive       bool   `json:"pid_alive" yaml:"pid_alive"`
+	ServerPortOpen bool   `json:"server_port_open" yaml:"server_port_open"`
+	MainPortOpen   bool   `json:"main_port_open" yaml:"main_port_open"`
+	Uptime         string `json:"uptime" yaml:"uptime"`
+	DeployPath     string `json:"deploy_path" yaml:"deploy_path"`
 }
 
 type Acquirers struct {
 	Acquirers map[string]Acquirer `yaml:"acquirers"`
 }
-type Acquirer struct {
-	Dev string `yaml:"dev"`
-	Sit string `yaml:"sit"`
-	Uat string `yaml:"uat"`
-}
+
+// Acquirer is the value of an acquirer for each env, keyed by env name.
+type Acquirer map[string]string
 
 type TomcatGlobalConfig struct {
-	Env        EnvConfig `mapstructure:"env"`
+	Env        EnvConfig                    `mapstructure:"env"`
+	Ports      PortRanges                   `mapstructure:"ports"`
+	Vars       map[string]string            `mapstructure:"vars"`
+	EnvVars    map[string]map[string]string `mapstructure:"env_vars"`
 	AppConfig  AppConfig
 	EnvToStart string
+	SetVars    map[string]string
+}
+
+// MergeVars returns the template variables of the app, from the lowest to the highest precedence:
+// global vars, vars of the env to start, app vars and the ones set on the command line.
+func (c *TomcatGlobalConfig) MergeVars() map[string]string {
+	merged := make(map[string]string)
+	for _, vars := range []map[string]string{c.Vars, c.EnvVars[c.EnvToStart], c.AppConfig.Vars, c.SetVars} {
+		for k, v := range vars {
+			merged[strings.ToLower(k)] = v
+		}
+	}
+	return merged
+}
+
+// PortRange is an inclusive range of ports, a zero value means the default range.
+type PortRange struct {
+	Start int `mapstructure:"start"`
+	End   int `mapstructure:"end"`
+}
+
+// PortRanges are the global ranges the ports of a tomcat are allocated from.
+type PortRanges struct {
+	Main      PortRange `mapstructure:"main"`
+	Server    PortRange `mapstructure:"server"`
+	Debug     PortRange `mapstructure:"debug"`
+	Connector PortRange `mapstructure:"connector"`
+	Redirect  PortRange `mapstructure:"redirect"`
+}
+
+// AppPorts pins the ports of an app, a zero value means allocated from the range.
+type AppPorts struct {
+	Main      int `mapstructure:"main"`
+	Server    int `mapstructure:"server"`
+	Debug     int `mapstructure:"debug"`
+	Connector int `mapstructure:"connector"`
+	Redirect  int `mapstructure:"redirect"`
 }
 
 func (c TomcatGlobalConfig) WithAppConfig(appConfig AppConfig) *TomcatGlobalConfig {
@@ -43,20 +128,98 @@ func (c TomcatGlobalConfig) WithAppConfig(appConfig AppConfig) *TomcatGlobalConf
 }
 
 type EnvConfig struct {
-	MvnSettings string `mapstructure:"mvn_settings"`
-	JavaHome    string `mapstructure:"java_home"`
-	JreHome     string `mapstructure:"jre_home"`
-	JavaOpts    string `mapstructure:"java_opts"`
+	MvnSettings    string      `mapstructure:"mvn_settings"`
+	MvnScript      string      `mapstructure:"mvn_script"`
+	CatalinaScript string      `mapstructure:"catalina_script"`
+	JavaHome       string      `mapstructure:"java_home"`
+	JreHome        string      `mapstructure:"jre_home"`
+	JavaOpts       string      `mapstructure:"java_opts"`
+	Maven          MavenConfig `mapstructure:",squash"`
+}
+
+// MavenConfig holds the maven build settings, defined in the env section and overridable per app.
+// Properties are written as "key=value" or "key" so their case is kept by viper.
+type MavenConfig struct {
+	MavenHome  string   `mapstructure:"maven_home"`
+	Goals      []string `mapstructure:"goals"`
+	Profiles   []string `mapstructure:"profiles"`
+	Properties []string `mapstructure:"properties"`
+	ExtraArgs  []string `mapstructure:"extra_args"`
 }
+
+var (
+	DefaultMvnGoals      = []string{"clean", "install"}
+	DefaultMvnProperties = []string{"env=tom", "skipTests"}
+)
+
+const DefaultMavenHome = "apache-maven-3.8.5"
+
+// MergeMaven returns the app maven config over the env one, with the defaults for the missing values.
+// Properties are merged by key, the other values are replaced when set in the app.
+func (c *TomcatGlobalConfig) MergeMaven() MavenConfig {
+	envMvn := c.Env.Maven
+	appMvn := c.AppConfig.Maven
+
+	merged := MavenConfig{
+		MavenHome:  firstNotEmpty(appMvn.MavenHome, envMvn.MavenHome, DefaultMavenHome),
+		Goals:      firstNotEmptySlice(appMvn.Goals, envMvn.Goals, DefaultMvnGoals),
+		Profiles:   firstNotEmptySlice(appMvn.Profiles, envMvn.Profiles),
+		ExtraArgs:  firstNotEmptySlice(appMvn.ExtraArgs, envMvn.ExtraArgs),
+		Properties: mergeProperties(firstNotEmptySlice(envMvn.Properties, DefaultMvnProperties), appMvn.Properties),
+	}
+	return merged
+}
+
+func mergeProperties(base, override []string) []string {
+	result := make([]string, 0, len(base)+len(override))
+	index := make(map[string]int, len(base)+len(override))
+	for _, prop := range append(append([]string{}, base...), override...) {
+		key, _, _ := strings.Cut(prop, "=")
+		if i, ok := index[key]; ok {
+			result[i] = prop
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, prop)
+	}
+	return result
+}
+
+func firstNotEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func firstNotEmptySlice(values ...[]string) []string {
+	for _, v := range values {
+		if len(v) > 0 {
+			return v
+		}
+	}
+	return nil
+}
+
 type AppConfig struct {
-	ContextFileName string `mapstructure:"context_file_name"`
-	WarName         string `mapstructure:"war_name"`
-	ProjectPath     string `mapstructure:"project_path"`
-	TargetSuffix    string `mapstructure:"target_suffix"`
-	JavaOpts        string `mapstructure:"java_opts"`
-	WithAppsConfig  bool   `mapstructure:"with_apps_config"`
-	WithAcquirer    bool   `mapstructure:"with_acquirer"`
-	IndexFile       string `mapstructure:"index_file"`
+	ContextFileName string            `mapstructure:"context_file_name"`
+	WarName         string            `mapstructure:"war_name"`
+	ProjectPath     string            `mapstructure:"project_path"`
+	TargetSuffix    string            `mapstructure:"target_suffix"`
+	JavaOpts        string            `mapstructure:"java_opts"`
+	WithAppsConfig  bool              `mapstructure:"with_apps_config"`
+	WithAcquirer    bool              `mapstructure:"with_acquirer"`
+	IndexFile       string            `mapstructure:"index_file"`
+	BuildTool       string            `mapstructure:"build_tool"`
+	GradleTasks     []string          `mapstructure:"gradle_tasks"`
+	ArtifactPath    string            `mapstructure:"artifact_path"`
+	UpdateInclude   []string          `mapstructure:"update_include"`
+	UpdateExclude   []string          `mapstructure:"update_exclude"`
+	Ports           AppPorts          `mapstructure:"ports"`
+	Vars            map[string]string `mapstructure:"vars"`
+	Maven           MavenConfig       `mapstructure:",squash"`
 }
 
 func GetAppConfig(appName string) (AppConfig, error) {
@@ -79,19 +242,49 @@ type TomcatPaths struct {
 	AppsConfigProps   string
 	CatalinaLocalhost string
 	Deploy            string
-	CatalinaBat       string
+	CatalinaScript    string
+	Logs              string
+	ConsoleLog        string
+	catalinaScript    string
+}
+
+// DefaultCatalinaScript returns the catalina launcher of the current os, relative to the tomcat home.
+func DefaultCatalinaScript() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join("bin", "catalina.bat")
+	}
+	return filepath.Join("bin", "catalina.sh")
 }
 
-func GetTomcatPaths(basePath, appTomcatName string) *TomcatPaths {
+// MvnScript returns the maven launcher of the current os inside the maven home.
+func MvnScript(mavenHome string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(mavenHome, "bin", "mvn.cmd")
+	}
+	return filepath.Join(mavenHome, "bin", "mvn")
+}
+
+func GetTomcatPaths(basePath, appTomcatName, catalinaScript string) *TomcatPaths {
 	p := TomcatPaths{}
 	p.CliBasePath = basePath
 	p.AppTomcatName = appTomcatName
-	p.HomeAppTomcat = filepath.Join(basePath, GoTomcatPrefix+appTomcatName)
+	if catalinaScript == "" {
+		catalinaScript = DefaultCatalinaScript()
+	}
+	p.catalinaScript = catalinaScript
+	return p.WithHome(filepath.Join(basePath, GoTomcatPrefix+appTomcatName))
+}
+
+// WithHome returns a copy of the paths with the tomcat home moved to home, e.g. to render in another folder.
+func (p TomcatPaths) WithHome(home string) *TomcatPaths {
+	p.HomeAppTomcat = home
 	p.ServerXml = filepath.Join(p.HomeAppTomcat, "conf", "server.xml")
 	p.ContextXml = filepath.Join(p.HomeAppTomcat, "conf", "context.xml")
 	p.AppsConfigProps = filepath.Join(p.HomeAppTomcat, "apps-config", "backend.properties")
 	p.CatalinaLocalhost = filepath.Join(p.HomeAppTomcat, "conf", "Catalina", "localhost")
 	p.Deploy = filepath.Join(p.HomeAppTomcat, "deploy")
-	p.CatalinaBat = filepath.Join(p.HomeAppTomcat, "bin", "catalina.bat")
+	p.CatalinaScript = filepath.Join(p.HomeAppTomcat, filepath.FromSlash(p.catalinaScript))
+	p.Logs = filepath.Join(p.HomeAppTomcat, "logs")
+	p.ConsoleLog = filepath.Join(p.Logs, "catalina.out")
 	return &p
 }
@@ -7,11 +7,76 @@ import (
 	"encoding/xml"
 )
 
+// The xml types keep the attributes and the elements they don't model in Attrs and Other,
+// so a file can be parsed, edited and written back without losing them. Comments are dropped.
+
+// Server is the root of server.xml.
+type Server struct {
+	XMLName               xml.Name               `xml:"Server"`
+	Port                  string                 `xml:"port,attr"`
+	Shutdown              string                 `xml:"shutdown,attr,omitempty"`
+	Attrs                 []xml.Attr             `xml:",any,attr"`
+	Other                 []AnyElement           `xml:",any"`
+	GlobalNamingResources *GlobalNamingResources `xml:"GlobalNamingResources"`
+	Services              []Service              `xml:"Service"`
+}
+
+type GlobalNamingResources struct {
+	Attrs     []xml.Attr   `xml:",any,attr"`
+	Other     []AnyElement `xml:",any"`
+	Resources []Resource   `xml:"Resource"`
+}
+
+type Service struct {
+	Name       string       `xml:"name,attr"`
+	Attrs      []xml.Attr   `xml:",any,attr"`
+	Other      []AnyElement `xml:",any"`
+	Connectors []Connector  `xml:"Connector"`
+	Engine     *Engine      `xml:"Engine"`
+}
+
+type Connector struct {
+	Port         string       `xml:"port,attr"`
+	Protocol     string       `xml:"protocol,attr,omitempty"`
+	RedirectPort string       `xml:"redirectPort,attr,omitempty"`
+	Attrs        []xml.Attr   `xml:",any,attr"`
+	Other        []AnyElement `xml:",any"`
+}
+
+type Engine struct {
+	Name        string       `xml:"name,attr"`
+	DefaultHost string       `xml:"defaultHost,attr"`
+	Attrs       []xml.Attr   `xml:",any,attr"`
+	Other       []AnyElement `xml:",any"`
+	Hosts       []Host       `xml:"Host"`
+}
+
+type Host struct {
+	Name    string       `xml:"name,attr"`
+	AppBase string       `xml:"appBase,attr,omitempty"`
+	Attrs   []xml.Attr   `xml:",any,attr"`
+	Other   []AnyElement `xml:",any"`
+}
+
+// Context is the app context file in conf/Catalina/localhost.
 type Context struct {
-	XMLName      xml.Name     `xml:"Context"`
-	ResourceLink ResourceLink `xml:"ResourceLink"`
-	Path         string       `xml:"path,attr"`
-	DocBase      string       `xml:"docBase,attr"`
+	XMLName       xml.Name       `xml:"Context"`
+	Path          string         `xml:"path,attr,omitempty"`
+	DocBase       string         `xml:"docBase,attr,omitempty"`
+	Attrs         []xml.Attr     `xml:",any,attr"`
+	Other         []AnyElement   `xml:",any"`
+	Resources     []Resource     `xml:"Resource"`
+	ResourceLinks []ResourceLink `xml:"ResourceLink"`
+}
+
+// Resource is a jndi resource, the pool and connection settings are kept in Attrs.
+type Resource struct {
+	XMLName xml.Name     `xml:"Resource"`
+	Name    string       `xml:"name,attr"`
+	Auth    string       `xml:"auth,attr,omitempty"`
+	Type    string       `xml:"type,attr"`
+	Attrs   []xml.Attr   `xml:",any,attr"`
+	Other   []AnyElement `xml:",any"`
 }
 
 type ResourceLink struct {
@@ -21,13 +86,89 @@ type ResourceLink struct {
 	Global  string   `xml:"global,attr"`
 }
 
+// AnyElement is an element kept as it is.
+type AnyElement struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXml string     `xml:",innerxml"`
+}
+
+// DbConfig is the content of .db-resources.yaml. DbResource and DbContext are the legacy raw xml form,
+// Datasources the structured one.
 type DbConfig struct {
-	DbResource DbResource `yaml:"db_resource"`
-	DbContext  DbResource `yaml:"db_context"`
-}
-type DbResource struct {
-	Local string `yaml:"local"`
-	Dev   string `yaml:"dev"`
-	Sit   string `yaml:"sit"`
-	Uat   string `yaml:"uat"`
+	DbResource  DbResource   `yaml:"db_resource"`
+	DbContext   DbResource   `yaml:"db_context"`
+	Datasources []Datasource `yaml:"datasources"`
+}
+
+// DbResource is the raw xml snippet of each env, keyed by env name.
+type DbResource map[string]string
+
+// Datasource is a jndi datasource, defined once and generated both as a global Resource in server.xml
+// and as a ResourceLink in the app context. Envs overrides the settings per env.
+type Datasource struct {
+	Name               string `yaml:"name"`
+	Type               string `yaml:"type,omitempty"`
+	DatasourceSettings `yaml:",inline"`
+	Envs               map[string]DatasourceSettings `yaml:"envs,omitempty"`
+}
+
+// DatasourceSettings are the connection and pool settings, Pool holds tomcat attributes like maxTotal.
+type DatasourceSettings struct {
+	Driver   string            `yaml:"driver,omitempty"`
+	Url      string            `yaml:"url,omitempty"`
+	User     string            `yaml:"user,omitempty"`
+	Password string            `yaml:"password,omitempty"`
+	Pool     map[string]string `yaml:"pool,omitempty"`
+}
+
+// ForEnv returns the settings of the datasource with the overrides of env, pool settings are merged by key.
+func (d Datasource) ForEnv(env string) DatasourceSettings {
+	settings := d.DatasourceSettings
+	override, ok := d.Envs[env]
+	if !ok {
+		return settings
+	}
+	if override.Driver != "" {
+		settings.Driver = override.Driver
+	}
+	if override.Url != "" {
+		settings.Url = override.Url
+	}
+	if override.User != "" {
+		settings.User = override.User
+	}
+	if override.Password != "" {
+		settings.Password = override.Password
+	}
+	pool := make(map[string]string, len(settings.Pool)+len(override.Pool))
+	for k, v := range settings.Pool {
+		pool[k] = v
+	}
+	for k, v := range override.Pool {
+		pool[k] = v
+	}
+	settings.Pool = pool
+	return settings
+}
+
+// TemplateData is the data the tomcat config files and the JAVA_OPTS are rendered with,
+// e.g. {{.MainPort}} or {{if eq .Env "local"}}.
+type TemplateData struct {
+	AppName          string
+	Env              string
+	Acquirer         string
+	CatalinaHome     string
+	ContextFileName  string
+	ProjectPath      string
+	TomcatDeployPath string
+	WarName          string
+	MainPort         int
+	ServerPort       int
+	DebugPort        int
+	ConnectorPort    int
+	RedirectPort     int
+	DbResources      string
+	DbContext        string
+	Vars             map[string]string
 }
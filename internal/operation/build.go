@@ -0,0 +1,149 @@
+package operation
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	MavenBuildTool  = "maven"
+	GradleBuildTool = "gradle"
+	NoneBuildTool   = "none"
+
+	mavenTargetSuffix  = "target"
+	gradleTargetSuffix = "build/libs"
+)
+
+var defaultGradleTasks = []string{"clean", "war"}
+
+// BuildTool builds the app and knows where its war is produced.
+type BuildTool interface {
+	Name() string
+	// BuildCommand returns nil when there is nothing to build.
+	BuildCommand(offline bool, adHocArgs []string) *exec.Cmd
+	FindArtifact() (string, error)
+}
+
+// GetBuildTool returns the build tool selected by build_tool in the app config, maven by default.
+func (ts *TomcatManager) GetBuildTool() (BuildTool, error) {
+	switch ts.TomcatConfig.AppConfig.BuildTool {
+	case "", MavenBuildTool:
+		return mavenBuild{ts: ts}, nil
+	case GradleBuildTool:
+		return gradleBuild{ts: ts}, nil
+	case NoneBuildTool:
+		return noneBuild{ts: ts}, nil
+	default:
+		return nil, fmt.Errorf("GetBuildTool : build tool not valid: %s. select one from the following list: %v",
+			ts.TomcatConfig.AppConfig.BuildTool, []string{MavenBuildTool, GradleBuildTool, NoneBuildTool})
+	}
+}
+
+type mavenBuild struct {
+	ts *TomcatManager
+}
+
+func (b mavenBuild) Name() string {
+	return MavenBuildTool
+}
+
+func (b mavenBuild) BuildCommand(offline bool, adHocArgs []string) *exec.Cmd {
+	return b.ts.GetMvnCommand(offline, adHocArgs)
+}
+
+func (b mavenBuild) FindArtifact() (string, error) {
+	return findWarInDir(b.ts.targetDir(mavenTargetSuffix), b.ts.TomcatConfig.AppConfig.WarName)
+}
+
+type gradleBuild struct {
+	ts *TomcatManager
+}
+
+func (b gradleBuild) Name() string {
+	return GradleBuildTool
+}
+
+func (b gradleBuild) BuildCommand(offline bool, adHocArgs []string) *exec.Cmd {
+	appConfig := b.ts.TomcatConfig.AppConfig
+
+	args := append([]string{}, defaultGradleTasks...)
+	if len(appConfig.GradleTasks) > 0 {
+		args = append([]string{}, appConfig.GradleTasks...)
+	}
+	args = append(args, adHocArgs...)
+	if offline {
+		args = append(args, "--offline")
+	}
+
+	gradlew := "gradlew"
+	if runtime.GOOS == "windows" {
+		gradlew = "gradlew.bat"
+	}
+	gradleCmd := exec.Command(filepath.Join(appConfig.ProjectPath, gradlew), args...)
+	gradleCmd.Dir = appConfig.ProjectPath
+	return gradleCmd
+}
+
+func (b gradleBuild) FindArtifact() (string, error) {
+	return findWarInDir(b.ts.targetDir(gradleTargetSuffix), b.ts.TomcatConfig.AppConfig.WarName)
+}
+
+// noneBuild uses an already built war found in artifact_path.
+type noneBuild struct {
+	ts *TomcatManager
+}
+
+func (b noneBuild) Name() string {
+	return NoneBuildTool
+}
+
+func (b noneBuild) BuildCommand(bool, []string) *exec.Cmd {
+	return nil
+}
+
+func (b noneBuild) FindArtifact() (string, error) {
+	appConfig := b.ts.TomcatConfig.AppConfig
+	if appConfig.ArtifactPath == "" {
+		return "", fmt.Errorf("FindArtifact : artifact_path is required with build_tool %s", NoneBuildTool)
+	}
+
+	artifactPath := b.ts.ResolvePath(appConfig.ArtifactPath)
+	info, err := os.Stat(artifactPath)
+	if err != nil {
+		return "", fmt.Errorf("FindArtifact : %w", err)
+	}
+	if strings.HasSuffix(info.Name(), ".war") {
+		return artifactPath, nil
+	}
+	return findWarInDir(artifactPath, appConfig.WarName)
+}
+
+// targetDir returns the project folder set in target_suffix, or the build tool default one.
+func (ts *TomcatManager) targetDir(defaultSuffix string) string {
+	suffix := ts.TomcatConfig.AppConfig.TargetSuffix
+	if suffix == "" {
+		suffix = defaultSuffix
+	}
+	return filepath.Join(ts.TomcatConfig.AppConfig.ProjectPath, filepath.FromSlash(suffix))
+}
+
+func findWarInDir(dir, warName string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("findWarInDir : %w", err)
+	}
+	var warPath string
+	for _, e := range entries {
+		if strings.Contains(e.Name(), warName) && strings.Contains(e.Name(), ".war") {
+			warPath = filepath.Join(dir, e.Name())
+		}
+	}
+	if warPath == "" {
+		return "", fmt.Errorf("findWarInDir : no %s war found in %s", warName, dir)
+	}
+	return warPath, nil
+}
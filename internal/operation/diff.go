@@ -0,0 +1,110 @@
+package operation
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+	aIdx int
+	bIdx int
+}
+
+// UnifiedDiff returns the unified diff of two texts, with 3 lines of context.
+// It returns an empty string when they are equal.
+func UnifiedDiff(fromName, toName, from, to string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	changes := make([]int, 0)
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+	for k := 0; k < len(changes); {
+		start := max(0, changes[k]-diffContext)
+		end := changes[k]
+		for k < len(changes) && changes[k] <= end+2*diffContext {
+			end = changes[k]
+			k++
+		}
+		end = min(len(ops)-1, end+diffContext)
+
+		hunk := ops[start : end+1]
+		aLen, bLen := 0, 0
+		for _, op := range hunk {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(hunk[0].aIdx, aLen), hunkRange(hunk[0].bIdx, bLen))
+		for _, op := range hunk {
+			b.WriteByte(op.kind)
+			b.WriteString(op.text)
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func hunkRange(idx, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", idx)
+	}
+	return fmt.Sprintf("%d,%d", idx+1, length)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+// diffLines computes the edit script of a into b from their longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], aIdx: i, bIdx: j})
+			i++
+			j++
+		case j >= len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: a[i], aIdx: i, bIdx: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], aIdx: i, bIdx: j})
+			j++
+		}
+	}
+	return ops
+}
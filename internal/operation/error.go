@@ -8,9 +8,9 @@ import (
 func CheckErr(err error, msg ...interface{}) {
 	if err != nil {
 		if len(msg) == 0 {
-			slog.Error("Error:", err)
+			slog.Error("Error", "error", err)
 		} else {
-			slog.Error("Error", err, ":", msg)
+			slog.Error("Error", "error", err, "message", msg)
 		}
 		os.Exit(1)
 	}
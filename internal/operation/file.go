@@ -31,25 +31,6 @@ func UpdatePropsInFiles(fileSlice []string, mapToReplace map[string]string) erro
 
 }
 
-func CheckInFile(fileListToCheck []string, sliceToCheck []string) error {
-
-	for _, fileToCheckPath := range fileListToCheck {
-		fileToCheck, err := os.ReadFile(fileToCheckPath)
-		if err != nil {
-			return fmt.Errorf("UpdatePropsInFiles : %w", err)
-		}
-		data := string(fileToCheck)
-
-		for _, toCheck := range sliceToCheck {
-			if strings.Contains(data, toCheck) {
-				return fmt.Errorf("CheckInFile : %w", fmt.Errorf("file %s still contains %s", fileToCheckPath, toCheck))
-			}
-		}
-	}
-
-	return nil
-}
-
 func CheckCopiedFiles(srcDir, targetDir string) error {
 	allSourceFilesPath := make([]string, 0)
 	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
@@ -70,3 +51,55 @@ func CheckCopiedFiles(srcDir, targetDir string) error {
 
 	return nil
 }
+
+// MakeScriptsExecutable adds the execute permission to the shell scripts in dir,
+// os.CopyFS keeps them only when the source already had it.
+func MakeScriptsExecutable(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("MakeScriptsExecutable : %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".sh" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("MakeScriptsExecutable : %w", err)
+		}
+		if err = os.Chmod(filepath.Join(dir, e.Name()), info.Mode()|0111); err != nil {
+			return fmt.Errorf("MakeScriptsExecutable : %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteFileAtomic writes data to a temp file in the same folder and renames it over path,
+// so readers never see a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("WriteFileAtomic : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileName is the name of both the global config and the project-local ones.
+const ConfigFileName = ".go-tomcat.yaml"
+
+// MergedOrigin is the origin of a value built from several files, like the apps list.
+const MergedOrigin = "merged"
+
+// MergeProjectConfigs deep-merges over the config of v the project-local config files: the one in the
+// project_path of every app, then the one in cwd. Maps are merged by key, other values replaced, the apps
+// lists are joined. An app defined in a project file without project_path gets the folder of the file.
+// It returns the file each effective key comes from.
+func MergeProjectConfigs(v *viper.Viper, cwd string) (map[string]string, error) {
+	origins := make(map[string]string)
+	globalFile := v.ConfigFileUsed()
+	if globalFile != "" {
+		global, err := readConfigLayer(globalFile)
+		if err != nil {
+			return nil, fmt.Errorf("MergeProjectConfigs : %w", err)
+		}
+		setOrigins(origins, "", global, globalFile)
+	}
+
+	var layerFiles []string
+	for _, app := range GetOrderedKeys(v.GetStringMap("app")) {
+		if projectPath := v.GetString("app." + app + ".project_path"); projectPath != "" {
+			layerFiles = append(layerFiles, filepath.Join(projectPath, ConfigFileName))
+		}
+	}
+	layerFiles = append(layerFiles, filepath.Join(cwd, ConfigFileName))
+
+	seen := map[string]bool{}
+	if abs, err := filepath.Abs(globalFile); err == nil && globalFile != "" {
+		seen[abs] = true
+	}
+	for _, file := range layerFiles {
+		abs, err := filepath.Abs(file)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+
+		layer, err := readConfigLayer(abs)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("MergeProjectConfigs : %w", err)
+		}
+
+		setProjectPaths(v, layer, filepath.Dir(abs))
+		apps := joinApps(v.GetStringSlice("apps"), layer)
+		layer["apps"] = apps
+		if err = v.MergeConfigMap(layer); err != nil {
+			return nil, fmt.Errorf("MergeProjectConfigs : %s: %w", abs, err)
+		}
+		setOrigins(origins, "", layer, abs)
+		origins["apps"] = MergedOrigin
+	}
+	return origins, nil
+}
+
+func readConfigLayer(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	layer := map[string]any{}
+	if err = yaml.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("readConfigLayer : %s: %w", path, err)
+	}
+	return layer, nil
+}
+
+// setProjectPaths sets the project_path of the apps of a project file that have none, relative ones are from dir.
+func setProjectPaths(v *viper.Viper, layer map[string]any, dir string) {
+	apps, _ := layer["app"].(map[string]any)
+	for name, appConfig := range apps {
+		app, ok := appConfig.(map[string]any)
+		if !ok {
+			continue
+		}
+		projectPath, _ := app["project_path"].(string)
+		switch {
+		case projectPath == "" && v.GetString("app."+name+".project_path") == "":
+			app["project_path"] = dir
+		case projectPath != "" && !filepath.IsAbs(projectPath):
+			app["project_path"] = filepath.Join(dir, projectPath)
+		}
+	}
+}
+
+// joinApps returns the apps already known plus the apps listed or defined in the layer.
+func joinApps(apps []string, layer map[string]any) []string {
+	joined := slices.Clone(apps)
+	add := func(app string) {
+		if !slices.Contains(joined, app) {
+			joined = append(joined, app)
+		}
+	}
+	if listed, ok := layer["apps"].([]any); ok {
+		for _, app := range listed {
+			add(fmt.Sprint(app))
+		}
+	}
+	if defined, ok := layer["app"].(map[string]any); ok {
+		for _, app := range GetOrderedKeys(defined) {
+			add(app)
+		}
+	}
+	return joined
+}
+
+// setOrigins records file as the origin of every leaf of the layer, keys are lowercase like viper ones.
+func setOrigins(origins map[string]string, prefix string, layer map[string]any, file string) {
+	for key, value := range layer {
+		fullKey := strings.ToLower(prefix + key)
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			setOrigins(origins, fullKey+".", nested, file)
+			continue
+		}
+		origins[fullKey] = file
+	}
+}
@@ -0,0 +1,155 @@
+package operation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UpdateManifest records the source files synced by update, by relative path.
+type UpdateManifest struct {
+	Files map[string]ManifestEntry `yaml:"files"`
+}
+
+// ManifestEntry identifies the synced content of a file, the hash is computed only when size or mtime change.
+type ManifestEntry struct {
+	Size    int64     `yaml:"size"`
+	ModTime time.Time `yaml:"mod_time"`
+	Hash    string    `yaml:"hash"`
+}
+
+// UpdatePlan is what an update copies and deletes, with the manifest to save once it is applied.
+type UpdatePlan struct {
+	Added     []string
+	Changed   []string
+	Deleted   []string
+	Unchanged []string
+	dirs      SyncDirs
+	manifest  UpdateManifest
+}
+
+func (p *UpdatePlan) String() string {
+	return fmt.Sprintf("added %d, changed %d, deleted %d, unchanged %d",
+		len(p.Added), len(p.Changed), len(p.Deleted), len(p.Unchanged))
+}
+
+// PlanUpdate compares the source files with the manifest of the last update and the tomcat folder.
+// With withDelete the files synced before and no longer in the source are deleted.
+func PlanUpdate(dirs SyncDirs, files []string, withDelete bool) (*UpdatePlan, error) {
+	previous, err := loadUpdateManifest(dirs.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("PlanUpdate : %w", err)
+	}
+	sourcePath := dirs.Source
+	destPath := dirs.Dest
+
+	plan := &UpdatePlan{dirs: dirs, manifest: UpdateManifest{Files: make(map[string]ManifestEntry, len(files))}}
+	for _, rel := range files {
+		info, err := os.Stat(filepath.Join(sourcePath, filepath.FromSlash(rel)))
+		if err != nil {
+			return nil, fmt.Errorf("PlanUpdate : %w", err)
+		}
+		entry := ManifestEntry{Size: info.Size(), ModTime: info.ModTime()}
+		old, synced := previous.Files[rel]
+		_, destErr := os.Stat(filepath.Join(destPath, filepath.FromSlash(rel)))
+
+		switch {
+		case !synced || destErr != nil:
+			plan.Added = append(plan.Added, rel)
+		case old.Size == entry.Size && old.ModTime.Equal(entry.ModTime):
+			entry.Hash = old.Hash
+			plan.Unchanged = append(plan.Unchanged, rel)
+		default:
+			if entry.Hash, err = hashFile(filepath.Join(sourcePath, filepath.FromSlash(rel))); err != nil {
+				return nil, fmt.Errorf("PlanUpdate : %w", err)
+			}
+			if entry.Hash == old.Hash {
+				plan.Unchanged = append(plan.Unchanged, rel)
+			} else {
+				plan.Changed = append(plan.Changed, rel)
+			}
+		}
+		if entry.Hash == "" {
+			if entry.Hash, err = hashFile(filepath.Join(sourcePath, filepath.FromSlash(rel))); err != nil {
+				return nil, fmt.Errorf("PlanUpdate : %w", err)
+			}
+		}
+		plan.manifest.Files[rel] = entry
+	}
+
+	for _, rel := range GetOrderedKeys(previous.Files) {
+		if _, inSource := plan.manifest.Files[rel]; inSource {
+			continue
+		}
+		if withDelete {
+			plan.Deleted = append(plan.Deleted, rel)
+		} else {
+			// kept, so a later update with delete still knows the file was synced
+			plan.manifest.Files[rel] = previous.Files[rel]
+		}
+	}
+	return plan, nil
+}
+
+// ApplyUpdate copies the added and changed files, deletes the deleted ones and saves the manifest.
+func ApplyUpdate(plan *UpdatePlan) error {
+	toCopy := append(append([]string{}, plan.Added...), plan.Changed...)
+	if err := CopyFiles(plan.dirs.Source, plan.dirs.Dest, toCopy); err != nil {
+		return fmt.Errorf("ApplyUpdate : %w", err)
+	}
+	for _, rel := range plan.Deleted {
+		err := os.Remove(filepath.Join(plan.dirs.Dest, filepath.FromSlash(rel)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("ApplyUpdate : %w", err)
+		}
+	}
+
+	data, err := yaml.Marshal(plan.manifest)
+	if err != nil {
+		return fmt.Errorf("ApplyUpdate : %w", err)
+	}
+	if err = WriteFileAtomic(plan.dirs.Manifest, data, 0644); err != nil {
+		return fmt.Errorf("ApplyUpdate : %w", err)
+	}
+	return nil
+}
+
+func loadUpdateManifest(path string) (UpdateManifest, error) {
+	manifest := UpdateManifest{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, fmt.Errorf("loadUpdateManifest : %w", err)
+	}
+	if err = yaml.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("loadUpdateManifest : %w", err)
+	}
+	return manifest, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("hashFile : %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashFile : %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HasChanges tells if applying the plan touches the tomcat.
+func (p *UpdatePlan) HasChanges() bool {
+	return len(p.Added)+len(p.Changed)+len(p.Deleted) > 0
+}
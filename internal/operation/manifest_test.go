@@ -0,0 +1,133 @@
+package operation
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanUpdate(t *testing.T) {
+	synced := time.Now().Add(-time.Hour).Truncate(time.Second)
+	later := synced.Add(time.Minute)
+
+	tests := []struct {
+		name       string
+		edit       func(t *testing.T, dirs SyncDirs)
+		files      []string
+		withDelete bool
+		want       UpdatePlan
+	}{
+		{
+			name:  "nothing changed",
+			files: []string{"index.jsp", "css/site.css", "old.js"},
+			want:  UpdatePlan{Unchanged: []string{"index.jsp", "css/site.css", "old.js"}},
+		},
+		{
+			name: "content changed",
+			edit: func(t *testing.T, dirs SyncDirs) {
+				writeTestFile(t, filepath.Join(dirs.Source, "index.jsp"), "<html>new</html>", later)
+			},
+			files: []string{"index.jsp", "css/site.css", "old.js"},
+			want:  UpdatePlan{Changed: []string{"index.jsp"}, Unchanged: []string{"css/site.css", "old.js"}},
+		},
+		{
+			name: "touched with the same content",
+			edit: func(t *testing.T, dirs SyncDirs) {
+				writeTestFile(t, filepath.Join(dirs.Source, "index.jsp"), "<html></html>", later)
+			},
+			files: []string{"index.jsp", "css/site.css", "old.js"},
+			want:  UpdatePlan{Unchanged: []string{"index.jsp", "css/site.css", "old.js"}},
+		},
+		{
+			name: "new file",
+			edit: func(t *testing.T, dirs SyncDirs) {
+				writeTestFile(t, filepath.Join(dirs.Source, "js/app.js"), "app()", later)
+			},
+			files: []string{"index.jsp", "css/site.css", "old.js", "js/app.js"},
+			want:  UpdatePlan{Added: []string{"js/app.js"}, Unchanged: []string{"index.jsp", "css/site.css", "old.js"}},
+		},
+		{
+			name: "missing in the tomcat folder",
+			edit: func(t *testing.T, dirs SyncDirs) {
+				if err := os.Remove(filepath.Join(dirs.Dest, "css", "site.css")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			files: []string{"index.jsp", "css/site.css", "old.js"},
+			want:  UpdatePlan{Added: []string{"css/site.css"}, Unchanged: []string{"index.jsp", "old.js"}},
+		},
+		{
+			name:  "removed from the source without delete",
+			files: []string{"index.jsp", "css/site.css"},
+			want:  UpdatePlan{Unchanged: []string{"index.jsp", "css/site.css"}},
+		},
+		{
+			name:       "removed from the source with delete",
+			files:      []string{"index.jsp", "css/site.css"},
+			withDelete: true,
+			want:       UpdatePlan{Deleted: []string{"old.js"}, Unchanged: []string{"index.jsp", "css/site.css"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			dirs := SyncDirs{
+				Source:   filepath.Join(dir, "src"),
+				Dest:     filepath.Join(dir, "dest"),
+				Manifest: filepath.Join(dir, ".update-manifest.yaml"),
+			}
+			writeTestFile(t, filepath.Join(dirs.Source, "index.jsp"), "<html></html>", synced)
+			writeTestFile(t, filepath.Join(dirs.Source, "css", "site.css"), "body {}", synced)
+			writeTestFile(t, filepath.Join(dirs.Source, "old.js"), "old()", synced)
+
+			first, err := PlanUpdate(dirs, []string{"index.jsp", "css/site.css", "old.js"}, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(first.Added) != 3 || !first.HasChanges() {
+				t.Fatalf("first update = %s, want 3 added", first)
+			}
+			if err = ApplyUpdate(first); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.edit != nil {
+				tt.edit(t, dirs)
+			}
+			plan, err := PlanUpdate(dirs, tt.files, tt.withDelete)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := UpdatePlan{Added: plan.Added, Changed: plan.Changed, Deleted: plan.Deleted, Unchanged: plan.Unchanged}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlanUpdate() = %+v, want %+v", got, tt.want)
+			}
+
+			if err = ApplyUpdate(plan); err != nil {
+				t.Fatal(err)
+			}
+			again, err := PlanUpdate(dirs, tt.files, tt.withDelete)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.HasChanges() {
+				t.Errorf("update after apply = %s, want no changes", again)
+			}
+		})
+	}
+}
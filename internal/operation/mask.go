@@ -0,0 +1,128 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const maskedValue = "****"
+
+var (
+	urlUserInfoRegex = regexp.MustCompile(`(://[^:/@]+:)[^@/]+@`)
+	urlPasswordRegex = regexp.MustCompile(`(?i)\b(password|pwd)=[^&;]*`)
+)
+
+// MaskUrl hides the password of a jdbc url, both as user:password@host and as a password parameter.
+func MaskUrl(url string) string {
+	url = urlUserInfoRegex.ReplaceAllString(url, "${1}"+maskedValue+"@")
+	return urlPasswordRegex.ReplaceAllString(url, "${1}="+maskedValue)
+}
+
+var secrets = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// RegisterSecret adds a resolved secret to the values hidden by Mask.
+func RegisterSecret(value string) {
+	if value == "" {
+		return
+	}
+	secrets.Lock()
+	defer secrets.Unlock()
+	secrets.values = append(secrets.values, value)
+}
+
+// Mask hides the registered secrets in s.
+func Mask(s string) string {
+	secrets.RLock()
+	defer secrets.RUnlock()
+	for _, value := range secrets.values {
+		s = strings.ReplaceAll(s, value, maskedValue)
+	}
+	return s
+}
+
+// MaskingHandler is a slog handler hiding the registered secrets in the message and in the attributes.
+// It writes in the format of the default logger: it can't wrap the default handler, which writes through
+// the log package that slog.SetDefault redirects to the new handler.
+type MaskingHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	level slog.Leveler
+	attrs string
+	group string
+}
+
+func NewMaskingHandler(w io.Writer, level slog.Leveler) *MaskingHandler {
+	return &MaskingHandler{w: w, mu: &sync.Mutex{}, level: level}
+}
+
+func (h *MaskingHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *MaskingHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if !r.Time.IsZero() {
+		b.WriteString(r.Time.Format("2006/01/02 15:04:05 "))
+	}
+	b.WriteString(r.Level.String())
+	b.WriteString(" ")
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		appendAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, Mask(b.String()))
+	return err
+}
+
+func (h *MaskingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		appendAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *MaskingHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+func appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		for _, ga := range v.Group() {
+			appendAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	value := v.String()
+	if v.Kind() == slog.KindAny {
+		value = fmt.Sprintf("%+v", v.Any())
+	}
+	value = Mask(value)
+	if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
@@ -0,0 +1,173 @@
+package operation
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+// templateKeywords are the bare actions of text/template that are not placeholders.
+var templateKeywords = []string{"else", "end", "break", "continue", "nil", "true", "false"}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Placeholder is a {{...}} token found in a file, with the closest known key as suggestion.
+type Placeholder struct {
+	File       string
+	Line       int
+	Column     int
+	Token      string
+	Suggestion string
+}
+
+func (p Placeholder) String() string {
+	msg := fmt.Sprintf("%s:%d:%d: unresolved placeholder %s", p.File, p.Line, p.Column, p.Token)
+	if p.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean {{%s}}?", p.Suggestion)
+	}
+	return msg
+}
+
+// UnresolvedPlaceholdersError lists all the placeholders found, one per line.
+type UnresolvedPlaceholdersError struct {
+	Placeholders []Placeholder
+}
+
+func (e *UnresolvedPlaceholdersError) Error() string {
+	lines := make([]string, 0, len(e.Placeholders)+1)
+	lines = append(lines, fmt.Sprintf("%d unresolved placeholders", len(e.Placeholders)))
+	for _, p := range e.Placeholders {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FindPlaceholders returns every {{...}} token in content.
+// With onlyUnknown, the template actions and the known keys are skipped, so it can run on a template before rendering.
+func FindPlaceholders(name, content string, knownKeys []string, onlyUnknown bool) []Placeholder {
+	found := make([]Placeholder, 0)
+	for lineIdx, line := range strings.Split(content, "\n") {
+		offset := 0
+		for {
+			start := strings.Index(line[offset:], "{{")
+			if start < 0 {
+				break
+			}
+			start += offset
+			end := strings.Index(line[start+2:], "}}")
+			token := line[start:]
+			if end >= 0 {
+				token = line[start : start+2+end+2]
+			}
+			offset = start + len(token)
+
+			key := strings.TrimSpace(strings.Trim(strings.TrimSuffix(strings.TrimPrefix(token, "{{"), "}}"), "-"))
+			if onlyUnknown && end >= 0 && (!bareKey.MatchString(key) || slices.Contains(knownKeys, key) || slices.Contains(templateKeywords, key)) {
+				continue
+			}
+			found = append(found, Placeholder{
+				File:       name,
+				Line:       lineIdx + 1,
+				Column:     utf8.RuneCountInString(line[:start]) + 1,
+				Token:      token,
+				Suggestion: ClosestKey(key, knownKeys),
+			})
+		}
+	}
+	return found
+}
+
+// CheckTemplates fails when the files or the JAVA_OPTS use {{key}} placeholders that are not known keys.
+// It runs before rendering, so a typo is reported with its position instead of a template parse error.
+func (ts *TomcatManager) CheckTemplates(fileList []string, data model.TemplateData) error {
+	knownKeys := knownPlaceholderKeys(data)
+	found, err := scanFiles(fileList, knownKeys, true)
+	if err != nil {
+		return fmt.Errorf("CheckTemplates : %w", err)
+	}
+	found = append(found, FindPlaceholders("JAVA_OPTS", ts.rawJavaOpts(), knownKeys, true)...)
+	if len(found) > 0 {
+		return fmt.Errorf("CheckTemplates : %w", &UnresolvedPlaceholdersError{Placeholders: found})
+	}
+	return nil
+}
+
+// CheckRendered fails when the rendered files or JAVA_OPTS still contain {{...}} tokens,
+// e.g. coming from the value of a var.
+func (ts *TomcatManager) CheckRendered(fileList []string, data model.TemplateData) error {
+	knownKeys := knownPlaceholderKeys(data)
+	found, err := scanFiles(fileList, knownKeys, false)
+	if err != nil {
+		return fmt.Errorf("CheckRendered : %w", err)
+	}
+	javaOpts, err := ts.RenderJavaOpts(data)
+	if err != nil {
+		return fmt.Errorf("CheckRendered : %w", err)
+	}
+	found = append(found, FindPlaceholders("JAVA_OPTS", javaOpts, knownKeys, false)...)
+	if len(found) > 0 {
+		return fmt.Errorf("CheckRendered : %w", &UnresolvedPlaceholdersError{Placeholders: found})
+	}
+	return nil
+}
+
+func scanFiles(fileList []string, knownKeys []string, onlyUnknown bool) ([]Placeholder, error) {
+	found := make([]Placeholder, 0)
+	for _, filePath := range fileList {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("scanFiles : %w", err)
+		}
+		found = append(found, FindPlaceholders(filePath, string(data), knownKeys, onlyUnknown)...)
+	}
+	return found, nil
+}
+
+func knownPlaceholderKeys(data model.TemplateData) []string {
+	keys := TemplateKeys(data)
+	for i, key := range keys {
+		keys[i] = strings.TrimSuffix(strings.TrimPrefix(key, "{{"), "}}")
+	}
+	return keys
+}
+
+// ClosestKey returns the known key with the smallest edit distance, if it is close enough to be a typo.
+func ClosestKey(key string, knownKeys []string) string {
+	best, bestDistance := "", -1
+	for _, known := range knownKeys {
+		d := levenshtein(strings.ToLower(key), known)
+		if bestDistance < 0 || d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	if bestDistance < 0 || bestDistance > max(2, len(key)/3) {
+		return ""
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,93 @@
+package operation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindPlaceholders(t *testing.T) {
+	knownKeys := []string{"port_http", "db_url", "env"}
+	tests := []struct {
+		name        string
+		content     string
+		onlyUnknown bool
+		want        []Placeholder
+	}{
+		{
+			name:    "no placeholder",
+			content: "<Server port=\"8005\">",
+			want:    []Placeholder{},
+		},
+		{
+			name:        "known key and template actions are skipped",
+			content:     "{{port_http}} {{ env }} {{- end -}} {{if .X}}",
+			onlyUnknown: true,
+			want:        []Placeholder{},
+		},
+		{
+			name:        "typo with suggestion",
+			content:     "url=\"jdbc\"\n  port=\"{{port_htp}}\"",
+			onlyUnknown: true,
+			want: []Placeholder{
+				{File: "f", Line: 2, Column: 9, Token: "{{port_htp}}", Suggestion: "port_http"},
+			},
+		},
+		{
+			name:        "unknown key without suggestion",
+			content:     "{{completely_different}}",
+			onlyUnknown: true,
+			want: []Placeholder{
+				{File: "f", Line: 1, Column: 1, Token: "{{completely_different}}"},
+			},
+		},
+		{
+			name:        "unclosed token",
+			content:     "a {{db_url",
+			onlyUnknown: true,
+			want: []Placeholder{
+				{File: "f", Line: 1, Column: 3, Token: "{{db_url", Suggestion: "db_url"},
+			},
+		},
+		{
+			name:    "every token when rendered",
+			content: "{{env}}ë{{db_url}}",
+			want: []Placeholder{
+				{File: "f", Line: 1, Column: 1, Token: "{{env}}", Suggestion: "env"},
+				{File: "f", Line: 1, Column: 9, Token: "{{db_url}}", Suggestion: "db_url"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FindPlaceholders("f", tt.content, knownKeys, tt.onlyUnknown)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindPlaceholders() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClosestKey(t *testing.T) {
+	knownKeys := []string{"port_http", "port_ajp", "db_url"}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"port_http", "port_http"},
+		{"port_htp", "port_http"},
+		{"PORT_AJP", "port_ajp"},
+		{"db_ulr", "db_url"},
+		{"acquirer", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := ClosestKey(tt.key, knownKeys); got != tt.want {
+				t.Errorf("ClosestKey(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+	if got := ClosestKey("port_http", nil); got != "" {
+		t.Errorf("ClosestKey() without keys = %q, want empty", got)
+	}
+}
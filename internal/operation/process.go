@@ -0,0 +1,176 @@
+package operation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+const (
+	hostToConnect         = "127.0.0.1"
+	tomcatShutdownCommand = "SHUTDOWN"
+	portPollInterval      = 500 * time.Millisecond
+)
+
+// sendShutdownCommand writes the shutdown command to the Tomcat server port,
+// the same way catalina stop does.
+func sendShutdownCommand(serverPort int) error {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostToConnect, fmt.Sprint(serverPort)), 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("sendShutdownCommand : %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.Write([]byte(tomcatShutdownCommand + "\n")); err != nil {
+		return fmt.Errorf("sendShutdownCommand : %w", err)
+	}
+	return nil
+}
+
+// waitForTomcatExit waits until the process of the tomcat has exited or the timeout expires.
+// The closed connectors are not enough, a tomcat stuck on non daemon threads keeps the jvm alive:
+// the ports are polled only when there is no pid to follow.
+func waitForTomcatExit(timeout time.Duration, pid int, ports ...int) bool {
+	if pid <= 0 {
+		return waitForPortsRelease(timeout, ports...)
+	}
+	deadline := time.Now().Add(timeout)
+	for isProcessAlive(pid) {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(portPollInterval)
+	}
+	return true
+}
+
+// waitForPortsRelease polls the given ports until nothing listens on them anymore or the timeout expires.
+// The probe is quiet, isFreePort would log every poll.
+func waitForPortsRelease(timeout time.Duration, ports ...int) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		allFree := true
+		for _, port := range ports {
+			if isPortOpen(port) {
+				allFree = false
+				break
+			}
+		}
+		if allFree {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(portPollInterval)
+	}
+}
+
+// findPidByPort returns the pid of the process listening on the given port.
+func findPidByPort(port int) (int, error) {
+	var out []byte
+	var err error
+	if runtime.GOOS == "windows" {
+		out, err = exec.Command("netstat", "-ano", "-p", "tcp").Output()
+	} else {
+		out, err = exec.Command("lsof", "-t", "-nP", fmt.Sprintf("-iTCP:%d", port), "-sTCP:LISTEN").Output()
+	}
+	if err != nil {
+		return 0, fmt.Errorf("findPidByPort : %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if runtime.GOOS != "windows" {
+			return strconv.Atoi(fields[0])
+		}
+		// Proto  Local Address  Foreign Address  State  PID
+		if len(fields) == 5 && fields[3] == "LISTENING" && strings.HasSuffix(fields[1], ":"+fmt.Sprint(port)) {
+			return strconv.Atoi(fields[4])
+		}
+	}
+	return 0, fmt.Errorf("findPidByPort : no process listening on port %d", port)
+}
+
+func isPortOpen(port int) bool {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(hostToConnect, fmt.Sprint(port)), time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// GetTomcatStatusList probes pid, server port and main port of every running tomcat.
+func GetTomcatStatusList(tomcatProps model.TomcatProps) []model.TomcatStatus {
+	statusList := make([]model.TomcatStatus, 0, len(tomcatProps.RunningTomcats))
+	for _, tomcat := range tomcatProps.RunningTomcats {
+		status := model.TomcatStatus{
+			AppTomcatName:  tomcat.AppTomcatName,
+			MainPort:       tomcat.MainPort,
+			ServerPort:     tomcat.ServerPort,
+			DebugPort:      tomcat.DebugPort,
+			ConnectorPort:  tomcat.ConnectorPort,
+			RedirectPort:   tomcat.RedirectPort,
+			Env:            tomcat.Env,
+			Acquirer:       tomcat.Acquirer,
+			Pid:            tomcat.Pid,
+			PidAlive:       tomcat.Pid > 0 && isProcessAlive(tomcat.Pid),
+			ServerPortOpen: isPortOpen(tomcat.ServerPort),
+			MainPortOpen:   isPortOpen(tomcat.MainPort),
+			DeployPath:     tomcat.DeployPath,
+		}
+
+		switch {
+		case tomcat.State == model.StateReserved:
+			status.Status = model.StatusStale
+			if isTomcatAlive(tomcat) {
+				status.Status = model.StatusReserved
+			}
+		case status.ServerPortOpen && status.MainPortOpen:
+			status.Status = model.StatusRunning
+		case status.PidAlive || status.ServerPortOpen:
+			status.Status = model.StatusStarting
+		default:
+			status.Status = model.StatusStale
+		}
+		if status.Status != model.StatusStale && !tomcat.StartedAt.IsZero() {
+			status.Uptime = time.Since(tomcat.StartedAt).Round(time.Second).String()
+		}
+		statusList = append(statusList, status)
+	}
+	return statusList
+}
+
+// isTomcatAlive uses the recorded pid when available and falls back to the server port otherwise.
+// A reserved tomcat is alive as long as the cli that reserved it.
+func isTomcatAlive(tomcat model.Tomcat) bool {
+	if tomcat.State == model.StateReserved {
+		return tomcat.ReservedBy > 0 && isProcessAlive(tomcat.ReservedBy)
+	}
+	if tomcat.Pid > 0 {
+		return isProcessAlive(tomcat.Pid)
+	}
+	return !isFreePort(fmt.Sprint(tomcat.ServerPort))
+}
+
+func killProcess(pid int) error {
+	if err := killProcessTree(pid); err != nil {
+		return fmt.Errorf("killProcess : %w", err)
+	}
+	slog.Info("Process killed", "pid", pid)
+	return nil
+}
@@ -0,0 +1,45 @@
+//go:build !windows
+
+package operation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detachProcess starts the command in its own session so it survives the cli.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// isProcessAlive tells if the process exists, a zombie waiting to be reaped has already exited.
+func isProcessAlive(pid int) bool {
+	err := syscall.Kill(pid, syscall.Signal(0))
+	if err != nil && !errors.Is(err, syscall.EPERM) {
+		return false
+	}
+	return !isZombie(pid)
+}
+
+// isZombie reads the state of the process in /proc, where there is one.
+func isZombie(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// the state follows the command name, that is in parentheses and can contain spaces
+	idx := bytes.LastIndexByte(stat, ')')
+	return idx >= 0 && idx+2 < len(stat) && stat[idx+2] == 'Z'
+}
+
+func killProcessTree(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
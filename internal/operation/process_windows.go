@@ -0,0 +1,30 @@
+//go:build windows
+
+package operation
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+const createNewProcessGroup = 0x00000200
+
+// detachProcess starts the command in a new process group so it survives the cli.
+func detachProcess(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: createNewProcessGroup}
+}
+
+func isProcessAlive(pid int) bool {
+	out, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/NH").Output()
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(out), fmt.Sprint(pid))
+}
+
+// killProcessTree uses taskkill because catalina.bat starts java as a child of cmd.exe.
+func killProcessTree(pid int) error {
+	return exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(pid)).Run()
+}
@@ -0,0 +1,188 @@
+package operation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	redeployPollInterval = 200 * time.Millisecond
+	// messages of the tomcat HostConfig for the context descriptor of the app, and of the context itself
+	// when a new war makes tomcat reload it instead of deploying it again
+	deployFinishedMessage = "has finished in"
+	deployFailedMessage   = "Error deploying deployment descriptor"
+	reloadFinishedMessage = "Reloading Context with name"
+	reloadCompleted       = "is completed"
+	startupFailedMessage  = "startup failed due to previous errors"
+)
+
+// ReplaceWar atomically replaces the war in the deploy folder with the artifact of the build tool:
+// it is copied next to the war and renamed over it, so tomcat never reads a partial war.
+func (ts *TomcatManager) ReplaceWar(buildTool BuildTool) error {
+	artifact, err := buildTool.FindArtifact()
+	if err != nil {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+	info, err := os.Stat(artifact)
+	if err != nil {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+
+	deployWar := filepath.Join(ts.TomcatPaths.Deploy, ts.TomcatConfig.AppConfig.WarName+".war")
+	tmpWar := deployWar + ".tmp"
+	_ = os.RemoveAll(tmpWar)
+
+	if !info.IsDir() {
+		if err = CopyFile(artifact, tmpWar); err != nil {
+			return fmt.Errorf("ReplaceWar : %w", err)
+		}
+		if err = os.Rename(tmpWar, deployWar); err != nil {
+			return fmt.Errorf("ReplaceWar : %w", err)
+		}
+		return nil
+	}
+
+	// an exploded war: the old folder is moved away before the new one takes its place
+	if err = os.CopyFS(tmpWar, os.DirFS(artifact)); err != nil {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+	oldWar := deployWar + ".old"
+	_ = os.RemoveAll(oldWar)
+	if err = os.Rename(deployWar, oldWar); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+	if err = os.Rename(tmpWar, deployWar); err != nil {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+	if err = os.RemoveAll(oldWar); err != nil {
+		return fmt.Errorf("ReplaceWar : %w", err)
+	}
+	return nil
+}
+
+// RedeployWatch follows the logs and the exploded folder of the app to know when tomcat has redeployed it.
+// It must be created before the war is replaced, only the log lines written after are considered.
+type RedeployWatch struct {
+	ts         *TomcatManager
+	logOffsets map[string]int64
+	start      time.Time
+}
+
+func (ts *TomcatManager) NewRedeployWatch() *RedeployWatch {
+	w := &RedeployWatch{ts: ts, logOffsets: make(map[string]int64), start: time.Now()}
+	for _, logFile := range w.logFiles() {
+		if info, err := os.Stat(logFile); err == nil {
+			w.logOffsets[logFile] = info.Size()
+		}
+	}
+	return w
+}
+
+// Wait waits for the deployment finished or the reload completed message of the app context in the logs,
+// or for a deployment error. The exploded folder removed and created again only means the war is unpacked,
+// the context can still fail to start: it is taken as the redeploy only when there are no log files to read.
+// It returns the time from the creation of the watch.
+func (w *RedeployWatch) Wait(timeout time.Duration) (time.Duration, error) {
+	descriptor := filepath.Base(w.ts.AppContextFile())
+	contextName := "[" + contextPath(descriptor) + "]"
+	explodedDir := w.ts.WebappDestPath()
+	explodedRemoved, unpacked := false, false
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		lines, err := w.newLogLines()
+		if err != nil {
+			return time.Since(w.start), fmt.Errorf("Wait : %w", err)
+		}
+		for _, line := range lines {
+			switch {
+			case strings.Contains(line, deployFailedMessage) && strings.Contains(line, descriptor),
+				strings.Contains(line, startupFailedMessage) && strings.Contains(line, contextName):
+				return time.Since(w.start), fmt.Errorf("Wait : redeploy failed: %s", strings.TrimSpace(line))
+			case strings.Contains(line, deployFinishedMessage) && strings.Contains(line, descriptor),
+				strings.Contains(line, reloadFinishedMessage) && strings.Contains(line, contextName) && strings.Contains(line, reloadCompleted):
+				return time.Since(w.start), nil
+			}
+		}
+
+		_, statErr := os.Stat(explodedDir)
+		if statErr != nil {
+			explodedRemoved = true
+		} else if explodedRemoved && !unpacked {
+			if !w.hasLogFiles() {
+				return time.Since(w.start), nil
+			}
+			unpacked = true
+			slog.Debug("War unpacked, waiting for the context to start", "dir", explodedDir)
+		}
+		time.Sleep(redeployPollInterval)
+	}
+	return time.Since(w.start), fmt.Errorf("Wait : the app was not redeployed within %s, check the logs in %s", timeout, w.ts.TomcatPaths.Logs)
+}
+
+// contextPath is the path of the context deployed by a descriptor: the file name without .xml,
+// with # for / and ROOT for the root context.
+func contextPath(descriptor string) string {
+	name := strings.TrimSuffix(descriptor, ".xml")
+	if name == "ROOT" {
+		return ""
+	}
+	return "/" + strings.ReplaceAll(name, "#", "/")
+}
+
+// logFiles are the console log of a detached tomcat and the catalina logs of juli.
+func (w *RedeployWatch) logFiles() []string {
+	files := []string{w.ts.TomcatPaths.ConsoleLog}
+	catalinaLogs, _ := filepath.Glob(filepath.Join(w.ts.TomcatPaths.Logs, "catalina.*.log"))
+	return append(files, catalinaLogs...)
+}
+
+// hasLogFiles tells if any of the log files can be read.
+func (w *RedeployWatch) hasLogFiles() bool {
+	for _, logFile := range w.logFiles() {
+		if f, err := os.Open(logFile); err == nil {
+			_ = f.Close()
+			return true
+		}
+	}
+	return false
+}
+
+// newLogLines returns the lines written in the log files since the last call, a new log file is read from the start.
+func (w *RedeployWatch) newLogLines() ([]string, error) {
+	var lines []string
+	for _, logFile := range w.logFiles() {
+		f, err := os.Open(logFile)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("newLogLines : %w", err)
+		}
+		offset := w.logOffsets[logFile]
+		if _, err = f.Seek(offset, io.SeekStart); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("newLogLines : %w", err)
+		}
+		reader := bufio.NewReader(f)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				// a partial line is read again at the next call
+				break
+			}
+			offset += int64(len(line))
+			lines = append(lines, line)
+		}
+		w.logOffsets[logFile] = offset
+		_ = f.Close()
+	}
+	return lines, nil
+}
@@ -0,0 +1,120 @@
+package operation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+func newRedeployTestManager(t *testing.T) *TomcatManager {
+	t.Helper()
+	home := t.TempDir()
+	paths := &model.TomcatPaths{
+		HomeAppTomcat:     home,
+		CatalinaLocalhost: filepath.Join(home, "conf", "Catalina", "localhost"),
+		Logs:              filepath.Join(home, "logs"),
+		ConsoleLog:        filepath.Join(home, "logs", "catalina.out"),
+	}
+	config := &model.TomcatGlobalConfig{AppConfig: model.AppConfig{ContextFileName: "my-app"}}
+	ts := &TomcatManager{TomcatConfig: config, TomcatPaths: paths}
+	if err := os.MkdirAll(ts.WebappDestPath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// redeployApp removes and recreates the exploded folder like tomcat unpacking the war, then writes the log lines.
+func redeployApp(t *testing.T, ts *TomcatManager, logLines ...string) {
+	t.Helper()
+	time.Sleep(2 * redeployPollInterval)
+	if err := os.RemoveAll(ts.WebappDestPath()); err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(2 * redeployPollInterval)
+	if err := os.MkdirAll(ts.WebappDestPath(), 0755); err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(2 * redeployPollInterval)
+	f, err := os.OpenFile(ts.TomcatPaths.ConsoleLog, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer f.Close()
+	for _, line := range logLines {
+		if _, err = f.WriteString(line + "\n"); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestRedeployWaitLogs(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr bool
+	}{
+		{
+			name: "deploy finished",
+			line: "INFO [Catalina-utility-1] org.apache.catalina.startup.HostConfig.deployDescriptor Deployment of deployment descriptor [/t/conf/Catalina/localhost/my-app.xml] has finished in [812] ms",
+		},
+		{
+			name: "reload completed",
+			line: "INFO [Catalina-utility-2] org.apache.catalina.core.StandardContext.reload Reloading Context with name [/my-app] is completed",
+		},
+		{
+			name:    "startup failed after the unpack",
+			line:    "SEVERE [Catalina-utility-1] org.apache.catalina.core.StandardContext.startInternal Context [/my-app] startup failed due to previous errors",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newRedeployTestManager(t)
+			if err := os.MkdirAll(ts.TomcatPaths.Logs, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(ts.TomcatPaths.ConsoleLog, []byte("INFO old line has finished in [1] ms my-app.xml\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			w := ts.NewRedeployWatch()
+			go redeployApp(t, ts, "INFO [Catalina-utility-1] Expanding web application archive [/t/deploy/my-app.war]", tt.line)
+			_, err := w.Wait(5 * time.Second)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Wait() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "startup failed") {
+				t.Errorf("Wait() error = %v, want the failed line", err)
+			}
+		})
+	}
+}
+
+func TestRedeployWaitWithoutLogs(t *testing.T) {
+	ts := newRedeployTestManager(t)
+	w := ts.NewRedeployWatch()
+	go redeployApp(t, ts)
+	if _, err := w.Wait(5 * time.Second); err != nil {
+		t.Errorf("Wait() error = %v, want the recreated folder taken as the redeploy", err)
+	}
+}
+
+func TestContextPath(t *testing.T) {
+	tests := map[string]string{
+		"my-app.xml":     "/my-app",
+		"ROOT.xml":       "",
+		"shop#admin.xml": "/shop/admin",
+	}
+	for descriptor, want := range tests {
+		if got := contextPath(descriptor); got != want {
+			t.Errorf("contextPath(%q) = %q, want %q", descriptor, got, want)
+		}
+	}
+}
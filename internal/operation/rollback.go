@@ -0,0 +1,51 @@
+package operation
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// Rollback is a stack of undo functions for the completed steps of a pipeline.
+// Run undoes them in reverse order; it is safe to call from a signal handler.
+type Rollback struct {
+	mu    sync.Mutex
+	steps []rollbackStep
+}
+
+type rollbackStep struct {
+	name string
+	undo func() error
+}
+
+func NewRollback() *Rollback {
+	return &Rollback{}
+}
+
+// Add pushes the undo function of a step that just completed.
+func (r *Rollback) Add(name string, undo func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, rollbackStep{name: name, undo: undo})
+}
+
+// Clear empties the stack without undoing anything, once the pipeline has gone past the point of no return.
+func (r *Rollback) Clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = nil
+}
+
+// Run undoes all the registered steps, last first, and empties the stack.
+// A failing undo is logged and does not stop the others.
+func (r *Rollback) Run() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.steps) - 1; i >= 0; i-- {
+		step := r.steps[i]
+		slog.Info("Rolling back", "step", step.name)
+		if err := step.undo(); err != nil {
+			slog.Error("Rollback failed", "step", step.name, "error", err)
+		}
+	}
+	r.steps = nil
+}
@@ -0,0 +1,284 @@
+package operation
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	secretsFileName = ".secrets.enc"
+	// legacySecretsKeyName is the key kept next to the secrets by the first versions, still read when found
+	legacySecretsKeyName = ".secrets.key"
+	secretsKeyDir        = "go-tomcat"
+	secretsKeyName       = "secrets.key"
+	secretsKeySize       = 32
+	// SecretKeyEnv overrides the path of the key file, by default in the user config folder.
+	SecretKeyEnv = "GTOM_SECRET_KEY_FILE"
+)
+
+// secretRefRegex matches ${secret:name} and ${env:VAR}.
+var secretRefRegex = regexp.MustCompile(`\$\{(secret|env):([^}\s]+)\}`)
+
+// SecretStore is a local file of named secrets, encrypted with AES-256-GCM.
+// The key is a random file created with the first secret, only readable by the user.
+// It is kept in the user config folder, away from the cli folder, so a copy of one is not enough to read the secrets.
+type SecretStore struct {
+	path    string
+	keyPath string
+	secrets map[string]string
+}
+
+// OpenSecretStore loads the secrets of the cli folder, a missing file is an empty store.
+func OpenSecretStore(basePath string) (*SecretStore, error) {
+	keyPath, err := secretKeyPath(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("OpenSecretStore : %w", err)
+	}
+	store := &SecretStore{
+		path:    filepath.Join(basePath, secretsFileName),
+		keyPath: keyPath,
+		secrets: map[string]string{},
+	}
+
+	data, err := os.ReadFile(store.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("OpenSecretStore : %w", err)
+	}
+	key, err := store.readKey(false)
+	if err != nil {
+		return nil, fmt.Errorf("OpenSecretStore : %w", err)
+	}
+	plain, err := decrypt(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("OpenSecretStore : %s: %w", store.path, err)
+	}
+	if err = yaml.Unmarshal(plain, &store.secrets); err != nil {
+		return nil, fmt.Errorf("OpenSecretStore : %w", err)
+	}
+	return store, nil
+}
+
+func (s *SecretStore) Get(name string) (string, bool) {
+	value, ok := s.secrets[name]
+	return value, ok
+}
+
+// Names returns the names of the secrets, sorted.
+func (s *SecretStore) Names() []string {
+	return GetOrderedKeys(s.secrets)
+}
+
+// Set stores the secret and writes the encrypted file.
+func (s *SecretStore) Set(name, value string) error {
+	s.secrets[name] = value
+	plain, err := yaml.Marshal(s.secrets)
+	if err != nil {
+		return fmt.Errorf("Set : %w", err)
+	}
+	key, err := s.readKey(true)
+	if err != nil {
+		return fmt.Errorf("Set : %w", err)
+	}
+	data, err := encrypt(key, plain)
+	if err != nil {
+		return fmt.Errorf("Set : %w", err)
+	}
+	if err = WriteFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("Set : %w", err)
+	}
+	return nil
+}
+
+// secretKeyPath is the key file of GTOM_SECRET_KEY_FILE, or the one in the user config folder.
+// A key left in the cli folder by the first versions is used when there is none in the user config folder.
+func secretKeyPath(basePath string) (string, error) {
+	if keyPath := os.Getenv(SecretKeyEnv); keyPath != "" {
+		return keyPath, nil
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("secretKeyPath : %w, set %s", err, SecretKeyEnv)
+	}
+	keyPath := filepath.Join(configDir, secretsKeyDir, secretsKeyName)
+	legacyKeyPath := filepath.Join(basePath, legacySecretsKeyName)
+	if _, err = os.Stat(keyPath); errors.Is(err, os.ErrNotExist) {
+		if _, err = os.Stat(legacyKeyPath); err == nil {
+			slog.Warn("The secrets key is in the cli folder, move it out of it", "key", legacyKeyPath, "to", keyPath)
+			return legacyKeyPath, nil
+		}
+	}
+	return keyPath, nil
+}
+
+// readKey reads the key file, creating it when missing and create is true.
+func (s *SecretStore) readKey(create bool) ([]byte, error) {
+	key, err := os.ReadFile(s.keyPath)
+	if errors.Is(err, os.ErrNotExist) && create {
+		key = make([]byte, secretsKeySize)
+		if _, err = rand.Read(key); err != nil {
+			return nil, fmt.Errorf("readKey : %w", err)
+		}
+		if err = os.MkdirAll(filepath.Dir(s.keyPath), 0700); err != nil {
+			return nil, fmt.Errorf("readKey : %w", err)
+		}
+		if err = WriteFileAtomic(s.keyPath, key, 0600); err != nil {
+			return nil, fmt.Errorf("readKey : %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("readKey : %w", err)
+	}
+	if len(key) != secretsKeySize {
+		return nil, fmt.Errorf("readKey : %s is not a valid key", s.keyPath)
+	}
+	return key, nil
+}
+
+func encrypt(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt : %w", err)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("encrypt : %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt : %w", err)
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("decrypt : file too short")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt : wrong key or corrupted file: %w", err)
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SecretResolver resolves the ${secret:name} and ${env:VAR} references, registering every value for masking.
+// The store is opened at the first secret reference.
+type SecretResolver struct {
+	basePath string
+	store    *SecretStore
+}
+
+func NewSecretResolver(basePath string) *SecretResolver {
+	return &SecretResolver{basePath: basePath}
+}
+
+// Resolve replaces the references in input, a missing secret or env var is an error.
+func (r *SecretResolver) Resolve(input string) (string, error) {
+	output, err := r.resolve(input, func(value string) string { return value })
+	if err != nil {
+		return "", fmt.Errorf("Resolve : %w", err)
+	}
+	return output, nil
+}
+
+// resolve replaces the references in input with their value passed through escape, both are masked.
+func (r *SecretResolver) resolve(input string, escape func(string) string) (string, error) {
+	var resolveErr error
+	output := secretRefRegex.ReplaceAllStringFunc(input, func(ref string) string {
+		if resolveErr != nil {
+			return ref
+		}
+		match := secretRefRegex.FindStringSubmatch(ref)
+		value, err := r.lookup(match[1], match[2])
+		if err != nil {
+			resolveErr = err
+			return ref
+		}
+		RegisterSecret(value)
+		RegisterSecret(escape(value))
+		return escape(value)
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return output, nil
+}
+
+func escapeXmlAttr(value string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+func (r *SecretResolver) lookup(kind, name string) (string, error) {
+	if kind == "env" {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("env var %s not set", name)
+		}
+		return value, nil
+	}
+
+	if r.store == nil {
+		store, err := OpenSecretStore(r.basePath)
+		if err != nil {
+			return "", err
+		}
+		r.store = store
+	}
+	value, ok := r.store.Get(name)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found, add it with: gtom secret set %s", name, name)
+	}
+	return value, nil
+}
+
+// ResolveFiles resolves the references in the files, rewriting only the ones that contain some.
+// Values are escaped in the xml files.
+func (r *SecretResolver) ResolveFiles(files []string) error {
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("ResolveFiles : %w", err)
+		}
+		if !strings.Contains(string(data), "${") {
+			continue
+		}
+		escape := func(value string) string { return value }
+		if strings.EqualFold(filepath.Ext(file), ".xml") {
+			escape = escapeXmlAttr
+		}
+		resolved, err := r.resolve(string(data), escape)
+		if err != nil {
+			return fmt.Errorf("ResolveFiles : %s: %w", file, err)
+		}
+		if err = os.WriteFile(file, []byte(resolved), 0644); err != nil {
+			return fmt.Errorf("ResolveFiles : %w", err)
+		}
+	}
+	return nil
+}
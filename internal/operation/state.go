@@ -0,0 +1,91 @@
+package operation
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	runningAppsLockName = runningAppsYamlName + ".lock"
+	lockTimeout         = 30 * time.Second
+	lockPollInterval    = 50 * time.Millisecond
+)
+
+// lockRunningApps takes the advisory lock shared by all the cli processes on the running apps file.
+// The returned function releases it.
+func lockRunningApps(basePath string) (func(), error) {
+	lockPath := filepath.Join(basePath, runningAppsLockName)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("lockRunningApps : %w", err)
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("lockRunningApps : %w", err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, fmt.Errorf("lockRunningApps : %s still locked after %s", lockPath, lockTimeout)
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	return func() {
+		if err := unlockFile(f); err != nil {
+			slog.Warn("Error unlocking running apps", "error", err)
+		}
+		_ = f.Close()
+	}, nil
+}
+
+// UpdateTomcatProps is a read-modify-write transaction on the running apps file:
+// it takes the lock, reloads the file, applies update and writes the result atomically.
+// Nothing is written if update returns an error.
+func UpdateTomcatProps(basePath string, update func(props *model.TomcatProps) error) (model.TomcatProps, error) {
+	unlock, err := lockRunningApps(basePath)
+	if err != nil {
+		return model.TomcatProps{}, fmt.Errorf("UpdateTomcatProps : %w", err)
+	}
+	defer unlock()
+
+	props, err := LoadTomcatProps(basePath)
+	if err != nil {
+		return model.TomcatProps{}, fmt.Errorf("UpdateTomcatProps : %w", err)
+	}
+	if err = update(&props); err != nil {
+		return model.TomcatProps{}, fmt.Errorf("UpdateTomcatProps : %w", err)
+	}
+
+	data, err := yaml.Marshal(props)
+	if err != nil {
+		return model.TomcatProps{}, fmt.Errorf("UpdateTomcatProps : %w", err)
+	}
+	if err = WriteFileAtomic(filepath.Join(basePath, runningAppsYamlName), data, 0644); err != nil {
+		return model.TomcatProps{}, fmt.Errorf("UpdateTomcatProps : %w", err)
+	}
+	return props, nil
+}
+
+// updateRunningApps runs the transaction and refreshes the running apps of the manager,
+// the current tomcat is kept as it is.
+func (ts *TomcatManager) updateRunningApps(update func(props *model.TomcatProps) error) error {
+	props, err := UpdateTomcatProps(ts.TomcatPaths.CliBasePath, update)
+	if err != nil {
+		return err
+	}
+	ts.TomcatProps.RunningTomcats = props.RunningTomcats
+	return nil
+}
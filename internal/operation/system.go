@@ -11,6 +11,8 @@ import (
 	"os/exec"
 	"sort"
 	"strings"
+
+	"github.com/charmbracelet/x/term"
 )
 
 func PrintCmd(cmd *exec.Cmd) {
@@ -28,24 +30,15 @@ func isFreePort(portToCheck string) bool {
 
 	conn, _ := net.Listen("tcp", net.JoinHostPort(hostToCheck, portToCheck))
 	if conn != nil {
-		err := conn.Close()
-		if err != nil {
-			CheckErr(err, "Error closing connection of portToCheck:"+portToCheck)
+		if err := conn.Close(); err != nil {
+			slog.Warn("Error closing connection", "port", portToCheck, "error", err)
 		}
 		return true
 	}
-	slog.Info("Port not available", portToCheck)
+	slog.Info("Port not available", "port", portToCheck)
 	return false
 }
 
-func sliceToSlice[T any](items []T, selector func(T) int) []int {
-	used := make([]int, 0, len(items))
-	for _, item := range items {
-		used = append(used, selector(item))
-	}
-	return used
-}
-
 // YesNoPrompt asks yes/no questions using the label.
 func YesNoPrompt(label string, def bool) bool {
 	choices := "Y/n"
@@ -93,6 +86,24 @@ func StringPrompt(label string) string {
 	return strings.TrimSpace(s)
 }
 
+// PasswordPrompt asks for a value without echoing it, when stdin is not a terminal it reads a line.
+func PasswordPrompt(label string) (string, error) {
+	if !term.IsTerminal(os.Stdin.Fd()) {
+		s, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("PasswordPrompt : %w", err)
+		}
+		return strings.TrimRight(s, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, label+" ")
+	value, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("PasswordPrompt : %w", err)
+	}
+	return string(value), nil
+}
+
 func GetOrderedKeys[V any](m map[string]V) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
@@ -0,0 +1,118 @@
+package operation
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"text/template"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+// NewTemplateData builds the template data from the current tomcat and the app config.
+func (ts *TomcatManager) NewTemplateData(dbResources, dbContext, acquirer string) model.TemplateData {
+	current := ts.TomcatProps.CurrentTomcat
+	paths := ts.TomcatPaths
+	if ts.TemplatePaths != nil {
+		paths = ts.TemplatePaths
+	}
+	return model.TemplateData{
+		AppName:          ts.TomcatPaths.AppTomcatName,
+		Env:              ts.TomcatConfig.EnvToStart,
+		Acquirer:         acquirer,
+		CatalinaHome:     paths.HomeAppTomcat,
+		ContextFileName:  ts.TomcatConfig.AppConfig.ContextFileName,
+		ProjectPath:      ts.TomcatConfig.AppConfig.ProjectPath,
+		TomcatDeployPath: paths.Deploy,
+		WarName:          ts.TomcatConfig.AppConfig.WarName,
+		MainPort:         current.MainPort,
+		ServerPort:       current.ServerPort,
+		DebugPort:        current.DebugPort,
+		ConnectorPort:    current.ConnectorPort,
+		RedirectPort:     current.RedirectPort,
+		DbResources:      dbResources,
+		DbContext:        dbContext,
+		Vars:             ts.TomcatConfig.MergeVars(),
+	}
+}
+
+var validFuncName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// legacyFuncs exposes the old {{key}} placeholders as template functions,
+// so the existing files render the same with text/template.
+// The user vars are added the same way, without overriding the built-in keys.
+func legacyFuncs(data model.TemplateData) template.FuncMap {
+	funcs := builtinFuncs(data)
+	for key, value := range data.Vars {
+		if _, builtin := funcs[key]; builtin {
+			slog.Warn("Var ignored, it has the same name of a built-in key", "var", key)
+			continue
+		}
+		if !validFuncName.MatchString(key) {
+			slog.Warn("Var not usable as {{key}}, use {{index .Vars \"key\"}}", "var", key)
+			continue
+		}
+		funcs[key] = func() string { return value }
+	}
+	return funcs
+}
+
+func builtinFuncs(data model.TemplateData) template.FuncMap {
+	return template.FuncMap{
+		"catalina_home":      func() string { return data.CatalinaHome },
+		"context_file_name":  func() string { return data.ContextFileName },
+		"debug_port":         func() int { return data.DebugPort },
+		"project_path":       func() string { return data.ProjectPath },
+		"tomcat_deploy_path": func() string { return data.TomcatDeployPath },
+		"war_name":           func() string { return data.WarName },
+		"main_port":          func() int { return data.MainPort },
+		"server_port":        func() int { return data.ServerPort },
+		"connector_port":     func() int { return data.ConnectorPort },
+		"redirect_port":      func() int { return data.RedirectPort },
+		"db_resources":       func() string { return data.DbResources },
+		"db_context":         func() string { return data.DbContext },
+		"acquirer":           func() string { return data.Acquirer },
+	}
+}
+
+// TemplateKeys returns the built-in and user placeholders in the {{key}} form.
+func TemplateKeys(data model.TemplateData) []string {
+	keys := GetOrderedKeys(legacyFuncs(data))
+	for i, key := range keys {
+		keys[i] = "{{" + key + "}}"
+	}
+	return keys
+}
+
+// RenderString executes input as a text/template with the given data.
+func RenderString(name, input string, data model.TemplateData) (string, error) {
+	tmpl, err := template.New(name).Funcs(legacyFuncs(data)).Option("missingkey=error").Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("RenderString : %w", err)
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("RenderString : %w", err)
+	}
+	return out.String(), nil
+}
+
+// RenderFiles renders every file in place.
+func RenderFiles(fileSlice []string, data model.TemplateData) error {
+	for _, filePath := range fileSlice {
+		input, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("RenderFiles : %w", err)
+		}
+		out, err := RenderString(filePath, string(input), data)
+		if err != nil {
+			return fmt.Errorf("RenderFiles : %w", err)
+		}
+		if err = os.WriteFile(filePath, []byte(out), 0644); err != nil {
+			return fmt.Errorf("RenderFiles : %w", err)
+		}
+	}
+	return nil
+}
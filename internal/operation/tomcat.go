@@ -6,37 +6,44 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"slices"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/nanaki-93/go-tomcat/internal/model"
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	goTomcatPrefix      = "go-tomcat-"
-	runningAppsYamlName = ".running-tomcats.yaml"
-	dbResourcesYamlName = ".db-resources.yaml"
-	acquirerYamlName    = ".acquirer.yaml"
-	hostToCheck         = "0.0.0.0"
-	StartMainPort       = 9000
-	StartServerPort     = 8000
-	StartDebugPort      = 5000
-	StartConnectorPort  = 8100
-	StartRedirectPort   = 8400
+	goTomcatPrefix       = "go-tomcat-"
+	runningAppsYamlName  = ".running-tomcats.yaml"
+	dbResourcesYamlName  = ".db-resources.yaml"
+	acquirerYamlName     = ".acquirer.yaml"
+	hostToCheck          = "0.0.0.0"
+	StartMainPort        = 9000
+	StartServerPort      = 8000
+	StartDebugPort       = 5000
+	StartConnectorPort   = 8100
+	StartRedirectPort    = 8400
+	defaultPortRangeSize = 100
 )
 
 type TomcatManager struct {
 	TomcatConfig *model.TomcatGlobalConfig
 	TomcatProps  *model.TomcatProps
 	TomcatPaths  *model.TomcatPaths
+	// TemplatePaths, when set, are the paths written in the templates instead of TomcatPaths,
+	// used to render in another folder as if it was the tomcat of the app.
+	TemplatePaths *model.TomcatPaths
+	Secrets       *SecretResolver
 }
 
 func NewTomcatManager(config *model.TomcatGlobalConfig, tomcatProps *model.TomcatProps, cliBasePath, appName string) *TomcatManager {
 	return &TomcatManager{
 		TomcatConfig: config,
 		TomcatProps:  tomcatProps,
-		TomcatPaths:  model.GetTomcatPaths(cliBasePath, appName),
+		TomcatPaths:  model.GetTomcatPaths(cliBasePath, appName, config.Env.CatalinaScript),
+		Secrets:      NewSecretResolver(cliBasePath),
 	}
 }
 
@@ -56,60 +63,161 @@ func LoadTomcatProps(basePath string) (model.TomcatProps, error) {
 }
 
 func (ts *TomcatManager) RemoveFromRunningAppsConfig() error {
-	//fixme reupload running tomcat from file
-	changed := false
-	for _, tomcat := range ts.TomcatProps.RunningTomcats {
-
-		if isFreePort(fmt.Sprint(tomcat.ServerPort)) {
-			ts.TomcatProps.RunningTomcats = RemoveTomcatFromRunning(ts.TomcatProps.RunningTomcats, tomcat.AppTomcatName)
-			changed = true
+	err := ts.updateRunningApps(func(props *model.TomcatProps) error {
+		for _, tomcat := range props.RunningTomcats {
+			if !isTomcatAlive(tomcat) {
+				props.RunningTomcats = RemoveTomcatFromRunning(props.RunningTomcats, tomcat.AppTomcatName)
+			}
 		}
-	}
-	if changed {
-		ts.UpdateAppRunningYaml()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("RemoveFromRunningAppsConfig : %w", err)
 	}
 	return nil
 }
 
 func (ts *TomcatManager) RemoveCurrentFromRunningAppsConfig() error {
-	//fixme reupload running tomcat from file
-	ts.TomcatProps.RunningTomcats = RemoveTomcatFromRunning(ts.TomcatProps.RunningTomcats, ts.TomcatProps.CurrentTomcat.AppTomcatName)
-	ts.UpdateAppRunningYaml()
+	err := ts.updateRunningApps(func(props *model.TomcatProps) error {
+		props.RunningTomcats = RemoveTomcatFromRunning(props.RunningTomcats, ts.TomcatProps.CurrentTomcat.AppTomcatName)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("RemoveCurrentFromRunningAppsConfig : %w", err)
+	}
+	return nil
+}
+
+// ReserveTomcatPorts allocates the ports of the current tomcat and saves it as reserved in the running apps,
+// in the same transaction, so concurrent starts can't hand out the same ports.
+// The reservation is released with RemoveCurrentFromRunningAppsConfig.
+func (ts *TomcatManager) ReserveTomcatPorts() error {
+	appTomcatName := ts.TomcatPaths.AppTomcatName
+	err := ts.updateRunningApps(func(props *model.TomcatProps) error {
+		if existing, found := findTomcat(props.RunningTomcats, appTomcatName); found && isTomcatAlive(existing) {
+			return fmt.Errorf("%s is already in the running apps, state %s", appTomcatName, existing.State)
+		}
+		others := RemoveTomcatFromRunning(props.RunningTomcats, appTomcatName)
+
+		current, err := ts.allocateTomcat(others)
+		if err != nil {
+			return err
+		}
+		current.State = model.StateReserved
+		current.ReservedBy = os.Getpid()
+
+		props.RunningTomcats = append(others, current)
+		ts.TomcatProps.CurrentTomcat = current
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("ReserveTomcatPorts : %w", err)
+	}
+	slog.Info("Tomcat ports reserved", "tomcat", ts.TomcatProps.CurrentTomcat)
+	return nil
+}
 
+// PreviewTomcatPorts sets the current tomcat without reserving anything:
+// the ports of the running instance of the app if any, otherwise the ones a start would allocate now.
+func (ts *TomcatManager) PreviewTomcatPorts() error {
+	if running, found := ts.FindRunningTomcat(ts.TomcatPaths.AppTomcatName); found {
+		ts.TomcatProps.CurrentTomcat = running
+		ts.TomcatProps.CurrentTomcat.Env = ts.TomcatConfig.EnvToStart
+		return nil
+	}
+	current, err := ts.allocateTomcat(ts.TomcatProps.RunningTomcats)
+	if err != nil {
+		return fmt.Errorf("PreviewTomcatPorts : %w", err)
+	}
+	ts.TomcatProps.CurrentTomcat = current
 	return nil
 }
 
-func (ts *TomcatManager) SetTomcatPorts() {
+// allocateTomcat picks the ports of the app avoiding the ones of the others.
+// Ports pinned in the app config are used as they are, the others come from the configured ranges.
+func (ts *TomcatManager) allocateTomcat(others []model.Tomcat) (model.Tomcat, error) {
+	appTomcatName := ts.TomcatPaths.AppTomcatName
+	ranges := ts.TomcatConfig.Ports
+	pinned := ts.TomcatConfig.AppConfig.Ports
+
+	usedPorts := make(map[int]string, len(others)*5)
+	for _, tomcat := range others {
+		for _, port := range tomcat.Ports() {
+			usedPorts[port] = tomcat.AppTomcatName
+		}
+	}
 
-	nextServerPort := findNextPort(sliceToSlice(ts.TomcatProps.RunningTomcats, func(t model.Tomcat) int { return t.ServerPort }), StartServerPort)
-	nextRedirectPort := findNextPort(sliceToSlice(ts.TomcatProps.RunningTomcats, func(t model.Tomcat) int { return t.RedirectPort }), StartRedirectPort)
-	nextMainPort := findNextPort(sliceToSlice(ts.TomcatProps.RunningTomcats, func(t model.Tomcat) int { return t.MainPort }), StartMainPort)
-	nextDebugPort := findNextPort(sliceToSlice(ts.TomcatProps.RunningTomcats, func(t model.Tomcat) int { return t.DebugPort }), StartDebugPort)
-	nextConnectorPort := findNextPort(sliceToSlice(ts.TomcatProps.RunningTomcats, func(t model.Tomcat) int { return t.ConnectorPort }), StartConnectorPort)
+	current := model.Tomcat{
+		AppTomcatName: appTomcatName,
+		Env:           ts.TomcatConfig.EnvToStart,
+		DeployPath:    ts.TomcatPaths.Deploy,
+	}
+	allocations := []struct {
+		kind         string
+		target       *int
+		pinned       int
+		portRange    model.PortRange
+		defaultStart int
+	}{
+		{"main", &current.MainPort, pinned.Main, ranges.Main, StartMainPort},
+		{"server", &current.ServerPort, pinned.Server, ranges.Server, StartServerPort},
+		{"debug", &current.DebugPort, pinned.Debug, ranges.Debug, StartDebugPort},
+		{"connector", &current.ConnectorPort, pinned.Connector, ranges.Connector, StartConnectorPort},
+		{"redirect", &current.RedirectPort, pinned.Redirect, ranges.Redirect, StartRedirectPort},
+	}
+	for _, a := range allocations {
+		port, err := allocatePort(a.kind, a.pinned, withDefaultRange(a.portRange, a.defaultStart), usedPorts)
+		if err != nil {
+			return model.Tomcat{}, err
+		}
+		usedPorts[port] = appTomcatName
+		*a.target = port
+	}
+	return current, nil
+}
 
-	ts.TomcatProps.CurrentTomcat = model.Tomcat{
-		AppTomcatName: ts.TomcatPaths.AppTomcatName,
-		MainPort:      nextMainPort,
-		ServerPort:    nextServerPort,
-		DebugPort:     nextDebugPort,
-		ConnectorPort: nextConnectorPort,
-		RedirectPort:  nextRedirectPort,
+func withDefaultRange(portRange model.PortRange, defaultStart int) model.PortRange {
+	if portRange.Start == 0 {
+		portRange.Start = defaultStart
 	}
-	slog.Info("Tomcat ports set", "tomcat", ts.TomcatProps.CurrentTomcat)
+	if portRange.End == 0 {
+		portRange.End = portRange.Start + defaultPortRangeSize - 1
+	}
+	return portRange
 }
 
-func findNextPort(usedPorts []int, startPort int) int {
-	nextServerPort := startPort
-	for i := 0; ; i++ {
-		nextServerPort = startPort + i
-		if !slices.Contains(usedPorts, nextServerPort) {
-			if isFreePort(fmt.Sprint(nextServerPort)) {
-				slog.Info("found free port", "nextServerPort", nextServerPort)
-				break
-			}
+func allocatePort(kind string, pinnedPort int, portRange model.PortRange, usedPorts map[int]string) (int, error) {
+	if pinnedPort > 0 {
+		if owner, used := usedPorts[pinnedPort]; used {
+			return 0, fmt.Errorf("allocatePort : pinned %s port %d is already allocated to %s", kind, pinnedPort, owner)
+		}
+		if !isFreePort(fmt.Sprint(pinnedPort)) {
+			return 0, fmt.Errorf("allocatePort : pinned %s port %d is in use by another process", kind, pinnedPort)
+		}
+		return pinnedPort, nil
+	}
+
+	if portRange.Start > portRange.End {
+		return 0, fmt.Errorf("allocatePort : %s port range not valid: %d-%d", kind, portRange.Start, portRange.End)
+	}
+	port, err := findNextPort(usedPorts, portRange)
+	if err != nil {
+		return 0, fmt.Errorf("allocatePort : %s %w", kind, err)
+	}
+	return port, nil
+}
+
+func findNextPort(usedPorts map[int]string, portRange model.PortRange) (int, error) {
+	for port := portRange.Start; port <= portRange.End; port++ {
+		if _, used := usedPorts[port]; used {
+			continue
+		}
+		if isFreePort(fmt.Sprint(port)) {
+			slog.Info("found free port", "port", port)
+			return port, nil
 		}
 	}
-	return nextServerPort
+	return 0, fmt.Errorf("no free port in range %d-%d", portRange.Start, portRange.End)
 }
 
 func RemoveTomcatFromRunning(runningTomcats []model.Tomcat, appTomcatNameToRemove string) []model.Tomcat {
@@ -127,43 +235,126 @@ func RemoveTomcatFromRunning(runningTomcats []model.Tomcat, appTomcatNameToRemov
 	return result
 }
 
-func (ts *TomcatManager) UpdateAppRunningYaml() {
-	data, err := yaml.Marshal(ts.TomcatProps)
-	CheckErr(err)
-	err = os.WriteFile(ts.JoinBasePath(runningAppsYamlName), data, os.ModePerm)
-	CheckErr(err)
-}
-
 func (ts *TomcatManager) CreateTomcat() error {
 	if err := os.CopyFS(ts.TomcatPaths.HomeAppTomcat, os.DirFS(ts.JoinBasePath("tomcat"))); err != nil {
 		return fmt.Errorf("CreateTomcat : %w", err)
 	}
+	if runtime.GOOS != "windows" {
+		if err := MakeScriptsExecutable(filepath.Dir(ts.TomcatPaths.CatalinaScript)); err != nil {
+			return fmt.Errorf("CreateTomcat : %w", err)
+		}
+	}
 	return nil
 }
 
-func (ts *TomcatManager) RunTomcat() error {
+// RunTomcat starts catalina and records its pid in the running apps.
+// With detach the process runs in background, writing its output to the console log.
+// Once the process is started the rollback is disarmed: the tomcat folder holds its logs,
+// so from then on an interrupt or a failed exit only removes the running apps entry.
+func (ts *TomcatManager) RunTomcat(detach bool, rollback *Rollback) error {
+
+	stCmd := exec.Command(ts.TomcatPaths.CatalinaScript, "run")
+	if detach {
+		if err := os.MkdirAll(ts.TomcatPaths.Logs, os.ModePerm); err != nil {
+			return fmt.Errorf("RunTomcat : %w", err)
+		}
+		logFile, err := os.OpenFile(ts.TomcatPaths.ConsoleLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("RunTomcat : %w", err)
+		}
+		defer logFile.Close()
+		stCmd.Stdout = logFile
+		stCmd.Stderr = logFile
+		detachProcess(stCmd)
+		slog.Info("Executing command", "command", stCmd.String(), "log", ts.TomcatPaths.ConsoleLog)
+	} else {
+		PrintCmd(stCmd)
+	}
+
+	if err := stCmd.Start(); err != nil {
+		return fmt.Errorf("RunTomcat : %w", err)
+	}
+	ts.TomcatProps.CurrentTomcat.Pid = stCmd.Process.Pid
+	ts.TomcatProps.CurrentTomcat.StartedAt = time.Now()
+	ts.TomcatProps.CurrentTomcat.State = model.StateRunning
+	ts.TomcatProps.CurrentTomcat.ReservedBy = 0
 
 	if err := ts.addTomcatToRunningApps(); err != nil {
+		_ = stCmd.Process.Kill()
 		return fmt.Errorf("RunTomcat : %w", err)
 	}
-	stCmd := exec.Command(ts.TomcatPaths.CatalinaBat, "run")
-	PrintCmd(stCmd)
-	if err := stCmd.Run(); err != nil {
+	rollback.Clear()
+	rollback.Add("running entry", ts.RemoveCurrentFromRunningAppsConfig)
+
+	if detach {
+		slog.Info("Tomcat started in background", "tomcat", ts.TomcatProps.CurrentTomcat.AppTomcatName, "pid", stCmd.Process.Pid)
+		if err := stCmd.Process.Release(); err != nil {
+			return fmt.Errorf("RunTomcat : %w", err)
+		}
+		return nil
+	}
+	if err := stCmd.Wait(); err != nil {
 		return fmt.Errorf("RunTomcat : %w", err)
 	}
 	return nil
 }
 
-func (ts *TomcatManager) addTomcatToRunningApps() error {
-	//write runningAppsConfig to .running-apps.yaml
+func (ts *TomcatManager) FindRunningTomcat(appTomcatName string) (model.Tomcat, bool) {
+	return findTomcat(ts.TomcatProps.RunningTomcats, appTomcatName)
+}
 
-	ts.TomcatProps.RunningTomcats = append(ts.TomcatProps.RunningTomcats, ts.TomcatProps.CurrentTomcat)
-	runningAppsYaml, err := yaml.Marshal(ts.TomcatProps)
-	if err != nil {
-		return fmt.Errorf("addTomcatToRunningApps : %w", err)
+func findTomcat(tomcats []model.Tomcat, appTomcatName string) (model.Tomcat, bool) {
+	for _, tomcat := range tomcats {
+		if tomcat.AppTomcatName == appTomcatName {
+			return tomcat, true
+		}
 	}
+	return model.Tomcat{}, false
+}
+
+// StopTomcat sends the shutdown command to the running instance and kills it
+// if its process has not exited once the timeout has expired.
+func (ts *TomcatManager) StopTomcat(timeout time.Duration) error {
+	tomcat, found := ts.FindRunningTomcat(ts.TomcatPaths.AppTomcatName)
+	if !found {
+		return fmt.Errorf("StopTomcat : %s is not in the running apps", ts.TomcatPaths.AppTomcatName)
+	}
+	ts.TomcatProps.CurrentTomcat = tomcat
 
-	if err = os.WriteFile(ts.JoinBasePath(runningAppsYamlName), runningAppsYaml, os.ModePerm); err != nil {
+	if err := sendShutdownCommand(tomcat.ServerPort); err != nil {
+		slog.Warn("Shutdown command not delivered", "tomcat", tomcat.AppTomcatName, "error", err)
+	}
+	if waitForTomcatExit(timeout, tomcat.Pid, tomcat.ServerPort, tomcat.MainPort) {
+		slog.Info("Tomcat stopped", "tomcat", tomcat.AppTomcatName)
+		return nil
+	}
+
+	slog.Warn("Tomcat still running, killing the process", "tomcat", tomcat.AppTomcatName, "timeout", timeout)
+	pid := tomcat.Pid
+	if pid <= 0 || !isProcessAlive(pid) {
+		var err error
+		if pid, err = findPidByPort(tomcat.ServerPort); err != nil {
+			if pid, err = findPidByPort(tomcat.MainPort); err != nil {
+				return fmt.Errorf("StopTomcat : %w", err)
+			}
+		}
+	}
+	if err := killProcess(pid); err != nil {
+		return fmt.Errorf("StopTomcat : %w", err)
+	}
+	if !waitForTomcatExit(timeout, pid, tomcat.ServerPort, tomcat.MainPort) {
+		return fmt.Errorf("StopTomcat : %s still running after killing pid %d", tomcat.AppTomcatName, pid)
+	}
+	return nil
+}
+
+func (ts *TomcatManager) addTomcatToRunningApps() error {
+	err := ts.updateRunningApps(func(props *model.TomcatProps) error {
+		props.RunningTomcats = append(RemoveTomcatFromRunning(props.RunningTomcats, ts.TomcatProps.CurrentTomcat.AppTomcatName),
+			ts.TomcatProps.CurrentTomcat)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("addTomcatToRunningApps : %w", err)
 	}
 	return nil
@@ -227,20 +418,16 @@ func (ts *TomcatManager) SetAcquirer(acquirerToSet string) (string, error) {
 	}
 
 	acquirer, exists := validAcquirer[acquirerToSet]
-	if !exists || acquirer == (model.Acquirer{}) {
-		return "", fmt.Errorf("SetAcquirer: acquirer not found for env %s", env)
+	if !exists {
+		return "", fmt.Errorf("SetAcquirer: acquirer %s not found", acquirerToSet)
 	}
-
-	switch env {
-	case "dev":
-		return acquirer.Dev, nil
-	case "sit":
-		return acquirer.Sit, nil
-	case "uat":
-		return acquirer.Uat, nil
-	default:
-		return "", fmt.Errorf("SetAcquirer: unsupported environment %s", env)
+	acquirerValue, exists := acquirer[env]
+	if !exists {
+		return "", fmt.Errorf("SetAcquirer: acquirer %s has no value for env %s", acquirerToSet, env)
 	}
+	ts.TomcatProps.CurrentTomcat.Acquirer = acquirerToSet
+
+	return acquirerValue, nil
 }
 
 func (ts *TomcatManager) getAcquirerList() (map[string]model.Acquirer, error) {
@@ -258,25 +445,14 @@ func (ts *TomcatManager) getAcquirerList() (map[string]model.Acquirer, error) {
 
 func (ts *TomcatManager) GetDbResources() (string, error) {
 
-	data, err := os.ReadFile(filepath.Join(ts.TomcatPaths.CliBasePath, dbResourcesYamlName))
+	dbConfig, err := ts.loadDbConfig()
 	if err != nil {
 		return "", fmt.Errorf("addDbResources : %w", err)
 	}
-	var dbConfig model.DbConfig
-	if err = yaml.Unmarshal(data, &dbConfig); err != nil {
-		return "", fmt.Errorf("addDbResources : %w", err)
-	}
 
-	dbResourceEnvMap := map[string]string{
-		"local": dbConfig.DbResource.Local,
-		"dev":   dbConfig.DbResource.Dev,
-		"sit":   dbConfig.DbResource.Sit,
-		"uat":   dbConfig.DbResource.Uat,
-	}
-	dbResourceToAdd, ok := dbResourceEnvMap[ts.TomcatConfig.EnvToStart]
+	dbResourceToAdd, ok := dbConfig.DbResource[ts.TomcatConfig.EnvToStart]
 	if !ok {
-		slog.Warn("env unknown, using dev")
-		dbResourceToAdd = dbConfig.DbResource.Dev
+		slog.Info("no db_resource for the env", "env", ts.TomcatConfig.EnvToStart)
 	}
 
 	return dbResourceToAdd, nil
@@ -284,25 +460,14 @@ func (ts *TomcatManager) GetDbResources() (string, error) {
 }
 func (ts *TomcatManager) GetDbContext() (string, error) {
 
-	data, err := os.ReadFile(filepath.Join(ts.TomcatPaths.CliBasePath, dbResourcesYamlName))
+	dbConfig, err := ts.loadDbConfig()
 	if err != nil {
 		return "", fmt.Errorf("GetDbContext : %w", err)
 	}
-	var dbConfig model.DbConfig
-	if err = yaml.Unmarshal(data, &dbConfig); err != nil {
-		return "", fmt.Errorf("GetDbContext : %w", err)
-	}
 
-	dbContextEnvMap := map[string]string{
-		"local": dbConfig.DbContext.Local,
-		"dev":   dbConfig.DbContext.Dev,
-		"sit":   dbConfig.DbContext.Sit,
-		"uat":   dbConfig.DbContext.Uat,
-	}
-	dbContextToAdd, ok := dbContextEnvMap[ts.TomcatConfig.EnvToStart]
+	dbContextToAdd, ok := dbConfig.DbContext[ts.TomcatConfig.EnvToStart]
 	if !ok {
-		slog.Warn("env unknown, using dev")
-		dbContextToAdd = dbConfig.DbContext.Dev
+		slog.Info("no db_context for the env", "env", ts.TomcatConfig.EnvToStart)
 	}
 
 	return dbContextToAdd,
@@ -310,10 +475,31 @@ func (ts *TomcatManager) GetDbContext() (string, error) {
 
 }
 
+// GetDatasources returns the structured datasources of .db-resources.yaml.
+func (ts *TomcatManager) GetDatasources() ([]model.Datasource, error) {
+	dbConfig, err := ts.loadDbConfig()
+	if err != nil {
+		return nil, fmt.Errorf("GetDatasources : %w", err)
+	}
+	return dbConfig.Datasources, nil
+}
+
+func (ts *TomcatManager) loadDbConfig() (model.DbConfig, error) {
+	data, err := os.ReadFile(filepath.Join(ts.TomcatPaths.CliBasePath, dbResourcesYamlName))
+	if err != nil {
+		return model.DbConfig{}, fmt.Errorf("loadDbConfig : %w", err)
+	}
+	var dbConfig model.DbConfig
+	if err = yaml.Unmarshal(data, &dbConfig); err != nil {
+		return model.DbConfig{}, fmt.Errorf("loadDbConfig : %s: %w", dbResourcesYamlName, err)
+	}
+	return dbConfig, nil
+}
+
 func (ts *TomcatManager) CopyAppContext() error {
 
 	inputContextPath := ts.JoinBasePath("contexts", ts.TomcatConfig.AppConfig.ContextFileName+".xml")
-	outputContextPath := filepath.Join(ts.TomcatPaths.CatalinaLocalhost, ts.TomcatConfig.AppConfig.ContextFileName+".xml")
+	outputContextPath := ts.AppContextFile()
 
 	data, err := os.ReadFile(inputContextPath)
 	if err != nil {
@@ -347,22 +533,25 @@ func (ts *TomcatManager) CopyIndexPage() (string, error) {
 
 }
 
-func (ts *TomcatManager) CopyAppToTomcat() error {
+func (ts *TomcatManager) CopyAppToTomcat(buildTool BuildTool) error {
 
-	appConfig := ts.TomcatConfig.AppConfig
-
-	entries, err := os.ReadDir(filepath.Join(appConfig.ProjectPath, appConfig.TargetSuffix))
+	targetAppToCopy, err := buildTool.FindArtifact()
 	if err != nil {
 		return fmt.Errorf("copyAppToTomcat : %w", err)
 	}
-	var targetAppToCopy string
-	for _, e := range entries {
-		if (strings.Contains(e.Name(), appConfig.WarName)) && (strings.Contains(e.Name(), ".war")) {
-			targetAppToCopy = filepath.Join(appConfig.ProjectPath, appConfig.TargetSuffix, e.Name())
-		}
+	info, err := os.Stat(targetAppToCopy)
+	if err != nil {
+		return fmt.Errorf("copyAppToTomcat : %w", err)
 	}
 
-	if err = os.CopyFS(filepath.Join(ts.TomcatPaths.Deploy, appConfig.WarName+".war"), os.DirFS(targetAppToCopy)); err != nil {
+	deployWar := filepath.Join(ts.TomcatPaths.Deploy, ts.TomcatConfig.AppConfig.WarName+".war")
+	if !info.IsDir() {
+		if err = CopyFile(targetAppToCopy, deployWar); err != nil {
+			return fmt.Errorf("copyAppToTomcat : %w", err)
+		}
+		return nil
+	}
+	if err = os.CopyFS(deployWar, os.DirFS(targetAppToCopy)); err != nil {
 		return fmt.Errorf("copyAppToTomcat : %w", err)
 	}
 	return nil
@@ -372,10 +561,10 @@ func (ts *TomcatManager) SetSystemEnv() error {
 
 	envConfig := ts.TomcatConfig.Env
 
-	if err := os.Setenv("JAVA_HOME", ts.JoinBasePath(envConfig.JavaHome)); err != nil {
+	if err := os.Setenv("JAVA_HOME", ts.ResolvePath(envConfig.JavaHome)); err != nil {
 		return fmt.Errorf("setSystemEnv : %w", err)
 	}
-	if err := os.Setenv("JRE_HOME", ts.JoinBasePath(envConfig.JreHome)); err != nil {
+	if err := os.Setenv("JRE_HOME", ts.ResolvePath(envConfig.JreHome)); err != nil {
 		return fmt.Errorf("setSystemEnv : %w", err)
 	}
 	if err := os.Setenv("CATALINA_HOME", ts.TomcatPaths.HomeAppTomcat); err != nil {
@@ -385,41 +574,72 @@ func (ts *TomcatManager) SetSystemEnv() error {
 	return nil
 }
 
-func (ts *TomcatManager) SetJavaOpts(keyToReplace map[string]string) error {
+func (ts *TomcatManager) rawJavaOpts() string {
+	return ts.TomcatConfig.Env.JavaOpts + " " + ts.TomcatConfig.AppConfig.JavaOpts
+}
 
-	envConfig := ts.TomcatConfig.Env
+func (ts *TomcatManager) RenderJavaOpts(data model.TemplateData) (string, error) {
+	javaOpts, err := RenderString("java_opts", ts.rawJavaOpts(), data)
+	if err != nil {
+		return "", fmt.Errorf("RenderJavaOpts : %w", err)
+	}
+	if javaOpts, err = ts.Secrets.Resolve(javaOpts); err != nil {
+		return "", fmt.Errorf("RenderJavaOpts : %w", err)
+	}
+	return javaOpts, nil
+}
 
-	javaOpts := envConfig.JavaOpts + " " + ts.TomcatConfig.AppConfig.JavaOpts
-	javaOpts = replaceKeysInString(javaOpts, keyToReplace)
-	fmt.Println("JAVA_OPTS: " + javaOpts)
+func (ts *TomcatManager) SetJavaOpts(data model.TemplateData) error {
+
+	javaOpts, err := ts.RenderJavaOpts(data)
+	if err != nil {
+		return fmt.Errorf("SetJavaOpts : %w", err)
+	}
+	fmt.Println("JAVA_OPTS: " + Mask(javaOpts))
 	if err := os.Setenv("JAVA_OPTS", javaOpts); err != nil {
-		return fmt.Errorf("setSystemEnv : %w", err)
+		return fmt.Errorf("SetJavaOpts : %w", err)
 	}
 	return nil
 }
-func replaceKeysInString(input string, keysToReplace map[string]string) string {
-	out := input
-	for oldStr, newStr := range keysToReplace {
-		out = strings.ReplaceAll(out, oldStr, newStr)
-	}
-	return out
-}
 
 func (ts *TomcatManager) JoinBasePath(suffix ...string) string {
 	joinSuffix := filepath.Join(suffix...)
 	return filepath.Join(ts.TomcatPaths.CliBasePath, joinSuffix)
 }
 
-func (ts *TomcatManager) GetMvnCommand(offline bool) *exec.Cmd {
-	args := []string{
-		"clean", "install",
+// ResolvePath returns the path as is when absolute, otherwise relative to the cli base path.
+func (ts *TomcatManager) ResolvePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return ts.JoinBasePath(filepath.FromSlash(path))
+}
+
+// GetMvnCommand builds the maven command from the merged env and app maven config,
+// adHocArgs are appended as they are.
+func (ts *TomcatManager) GetMvnCommand(offline bool, adHocArgs []string) *exec.Cmd {
+	mvnConfig := ts.TomcatConfig.MergeMaven()
+
+	args := append([]string{}, mvnConfig.Goals...)
+	args = append(args,
 		"-f", ts.TomcatConfig.AppConfig.ProjectPath,
-		"-s", filepath.Join(ts.TomcatPaths.CliBasePath, ts.TomcatConfig.Env.MvnSettings),
-		"-Denv=tom", "-DskipTests",
+		"-s", ts.ResolvePath(ts.TomcatConfig.Env.MvnSettings),
+	)
+	if len(mvnConfig.Profiles) > 0 {
+		args = append(args, "-P", strings.Join(mvnConfig.Profiles, ","))
 	}
+	for _, prop := range mvnConfig.Properties {
+		args = append(args, "-D"+prop)
+	}
+	args = append(args, mvnConfig.ExtraArgs...)
+	args = append(args, adHocArgs...)
 	if offline {
 		args = append(args, "-o")
 	}
-	mvnCmd := ts.JoinBasePath("apache-maven-3.8.5", "bin", "mvn.cmd")
-	return exec.Command(mvnCmd, args...)
+
+	mvnScript := ts.TomcatConfig.Env.MvnScript
+	if mvnScript == "" {
+		mvnScript = model.MvnScript(mvnConfig.MavenHome)
+	}
+	return exec.Command(ts.ResolvePath(mvnScript), args...)
 }
@@ -0,0 +1,236 @@
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const copyPoolSize = 100
+
+const (
+	webappManifestName  = ".update-manifest.yaml"
+	classesManifestName = ".update-classes-manifest.yaml"
+)
+
+var webappFolderSuffix = filepath.Join("src", "main", "webapp")
+
+// webappDefaultExclude are the webapp files built by maven into the war: the filtered web.xml, watched by tomcat
+// so copying it reloads the context, and the jars and classes. They are synced only when update_include matches them.
+var webappDefaultExclude = []string{"WEB-INF/web.xml", "WEB-INF/lib/**", "WEB-INF/classes/**"}
+
+// SyncDirs is a source folder of the project synced by update into a folder of the tomcat.
+// Include selects the files by their slash separated path relative to Source.
+type SyncDirs struct {
+	Source   string
+	Dest     string
+	Manifest string
+	Include  func(rel string) bool
+}
+
+// WebappSync syncs the webapp folder into the exploded app, filtered by the include and exclude globs.
+func (ts *TomcatManager) WebappSync() SyncDirs {
+	return SyncDirs{
+		Source:   ts.WebappSourcePath(),
+		Dest:     ts.WebappDestPath(),
+		Manifest: filepath.Join(ts.TomcatPaths.HomeAppTomcat, webappManifestName),
+		Include:  ts.IsWebappFile,
+	}
+}
+
+// ClassesSync syncs the compiled classes and resources of target/classes into WEB-INF/classes of the exploded app.
+func (ts *TomcatManager) ClassesSync() SyncDirs {
+	return SyncDirs{
+		Source:   filepath.Join(ts.targetDir(mavenTargetSuffix), "classes"),
+		Dest:     filepath.Join(ts.WebappDestPath(), "WEB-INF", "classes"),
+		Manifest: filepath.Join(ts.TomcatPaths.HomeAppTomcat, classesManifestName),
+		Include:  func(string) bool { return true },
+	}
+}
+
+// WebappSourcePath is the webapp folder of the project.
+func (ts *TomcatManager) WebappSourcePath() string {
+	return filepath.Join(ts.TomcatConfig.AppConfig.ProjectPath, webappFolderSuffix)
+}
+
+// WebappDestPath is the exploded webapp folder of the app in the tomcat.
+func (ts *TomcatManager) WebappDestPath() string {
+	return filepath.Join(ts.TomcatPaths.HomeAppTomcat, "webapps", ts.TomcatConfig.AppConfig.ContextFileName)
+}
+
+// Files walks the source folder and returns the slash separated paths, relative to it, of the included files.
+func (s SyncDirs) Files() ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(s.Source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.Source, p)
+		if err != nil {
+			return err
+		}
+		if s.Include(filepath.ToSlash(rel)) {
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Files : %w", err)
+	}
+	return files, nil
+}
+
+// IsWebappFile tells if the relative path is selected by the update_include globs of the app (all when empty)
+// and by none of the update_exclude ones. The files of webappDefaultExclude need an update_include glob.
+func (ts *TomcatManager) IsWebappFile(rel string) bool {
+	appConfig := ts.TomcatConfig.AppConfig
+	included := matchAnyGlob(appConfig.UpdateInclude, rel)
+	if len(appConfig.UpdateInclude) > 0 && !included {
+		return false
+	}
+	if !included && matchAnyGlob(webappDefaultExclude, rel) {
+		return false
+	}
+	return !matchAnyGlob(appConfig.UpdateExclude, rel)
+}
+
+func matchAnyGlob(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchGlob matches a slash separated path against a glob: * and ? don't cross folders, ** does.
+// A pattern without a slash is matched against the file name, e.g. *.jsp.
+func MatchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		rel = path.Base(rel)
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			expr.WriteString("[^/]*")
+		case pattern[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return false
+	}
+	return re.MatchString(rel)
+}
+
+// CopyFiles copies the files, relative to srcDir, to the same path in dstDir creating the folders.
+// Up to copyPoolSize files are copied at a time, the first error is returned.
+func CopyFiles(srcDir, dstDir string, files []string) error {
+	pool := make(chan struct{}, copyPoolSize)
+	wg := new(sync.WaitGroup)
+	var once sync.Once
+	var firstErr error
+
+	for _, rel := range files {
+		pool <- struct{}{} // acquire slot
+		wg.Add(1)
+		go func(rel string) {
+			defer wg.Done()
+			defer func() { <-pool }() // release slot
+			src := filepath.Join(srcDir, filepath.FromSlash(rel))
+			dst := filepath.Join(dstDir, filepath.FromSlash(rel))
+			if err := CopyFile(src, dst); err != nil {
+				once.Do(func() { firstErr = err })
+			}
+		}(rel)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return fmt.Errorf("CopyFiles : %w", firstErr)
+	}
+	return nil
+}
+
+// CopyFile copies the contents of the file named src to the file named dst, creating its folder.
+// If the destination file exists, all its contents are replaced by the contents of the source file.
+func CopyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("CopyFile : %w", err)
+	}
+	defer in.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("CopyFile : %w", err)
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("CopyFile : %w", err)
+	}
+	defer func() {
+		cerr := out.Close()
+		if err == nil && cerr != nil {
+			err = fmt.Errorf("CopyFile : %w", cerr)
+		}
+	}()
+	if _, err = io.Copy(out, in); err != nil {
+		return fmt.Errorf("CopyFile : %w", err)
+	}
+	return out.Sync()
+}
+
+// CheckReload fails when the exploded app has no WEB-INF/web.xml to touch, e.g. a servlet 3 app without descriptor.
+func (ts *TomcatManager) CheckReload() error {
+	webXml := ts.explodedWebXml()
+	_, err := os.Stat(webXml)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("CheckReload : --reload needs a WEB-INF/web.xml in the app, the file touched to reload the context: %s not found", webXml)
+	}
+	if err != nil {
+		return fmt.Errorf("CheckReload : %w", err)
+	}
+	return nil
+}
+
+func (ts *TomcatManager) explodedWebXml() string {
+	return filepath.Join(ts.WebappDestPath(), "WEB-INF", "web.xml")
+}
+
+// ReloadAppContext touches WEB-INF/web.xml of the exploded app, a resource watched by tomcat: the context
+// is reloaded keeping the exploded folder. Touching the context descriptor instead would redeploy the app,
+// deleting the exploded folder with the files just synced in it.
+func (ts *TomcatManager) ReloadAppContext() error {
+	if err := ts.CheckReload(); err != nil {
+		return fmt.Errorf("ReloadAppContext : %w", err)
+	}
+	webXml := ts.explodedWebXml()
+	now := time.Now()
+	if err := os.Chtimes(webXml, now, now); err != nil {
+		return fmt.Errorf("ReloadAppContext : %w", err)
+	}
+	slog.Info("Context reload triggered", "resource", webXml)
+	return nil
+}
@@ -0,0 +1,103 @@
+package operation
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.jsp", "index.jsp", true},
+		{"*.jsp", "pages/admin/index.jsp", true},
+		{"*.jsp", "index.jspf", false},
+		{"pages/*.jsp", "pages/index.jsp", true},
+		{"pages/*.jsp", "pages/admin/index.jsp", false},
+		{"pages/**/*.jsp", "pages/index.jsp", true},
+		{"pages/**/*.jsp", "pages/admin/users/index.jsp", true},
+		{"**/*.css", "css/site.css", true},
+		{"**/*.css", "site.css", true},
+		{"WEB-INF/**", "WEB-INF/lib/a.jar", true},
+		{"WEB-INF/**", "static/WEB-INF/a.jar", false},
+		{"img/?.png", "img/a.png", true},
+		{"img/?.png", "img/ab.png", false},
+		{"a.b/*.js", "axb/app.js", false},
+		{"a.b/*.js", "a.b/app.js", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.rel, func(t *testing.T) {
+			if got := MatchGlob(tt.pattern, tt.rel); got != tt.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsWebappFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		rel     string
+		want    bool
+	}{
+		{name: "jsp by default", rel: "pages/index.jsp", want: true},
+		{name: "web.xml excluded by default", rel: "WEB-INF/web.xml", want: false},
+		{name: "jars excluded by default", rel: "WEB-INF/lib/a.jar", want: false},
+		{name: "classes excluded by default", rel: "WEB-INF/classes/a/B.class", want: false},
+		{name: "other WEB-INF files by default", rel: "WEB-INF/tags/field.tag", want: true},
+		{name: "web.xml named by include", include: []string{"**/*.jsp", "WEB-INF/web.xml"}, rel: "WEB-INF/web.xml", want: true},
+		{name: "jars named by include", include: []string{"WEB-INF/lib/**"}, rel: "WEB-INF/lib/a.jar", want: true},
+		{name: "not included", include: []string{"**/*.jsp"}, rel: "css/site.css", want: false},
+		{name: "included and excluded", include: []string{"WEB-INF/lib/**"}, exclude: []string{"*.jar"}, rel: "WEB-INF/lib/a.jar", want: false},
+		{name: "excluded", exclude: []string{"*.bak"}, rel: "index.jsp.bak", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := &TomcatManager{TomcatConfig: &model.TomcatGlobalConfig{
+				AppConfig: model.AppConfig{UpdateInclude: tt.include, UpdateExclude: tt.exclude},
+			}}
+			if got := ts.IsWebappFile(tt.rel); got != tt.want {
+				t.Errorf("IsWebappFile(%q) = %v, want %v", tt.rel, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadAppContext(t *testing.T) {
+	home := t.TempDir()
+	ts := &TomcatManager{
+		TomcatConfig: &model.TomcatGlobalConfig{AppConfig: model.AppConfig{ContextFileName: "my-app"}},
+		TomcatPaths:  &model.TomcatPaths{HomeAppTomcat: home},
+	}
+	webXml := filepath.Join(ts.WebappDestPath(), "WEB-INF", "web.xml")
+	if err := os.MkdirAll(filepath.Dir(webXml), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := ts.ReloadAppContext()
+	if err == nil || !strings.Contains(err.Error(), "--reload needs a WEB-INF/web.xml") {
+		t.Errorf("ReloadAppContext() without web.xml error = %v, want the missing web.xml", err)
+	}
+
+	old := time.Now().Add(-time.Hour)
+	writeTestFile(t, webXml, "<web-app/>", old)
+	if err = ts.ReloadAppContext(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(webXml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().After(old) {
+		t.Errorf("web.xml mtime = %v, want touched", info.ModTime())
+	}
+}
@@ -0,0 +1,148 @@
+package operation
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	WatchDebounce        = 100 * time.Millisecond
+	watchRunningInterval = 2 * time.Second
+)
+
+// Watch copies the files of dirs to the tomcat as they change, a burst of events is synced once
+// after debounce and then afterSync, if not nil, is called. It returns when ctx is done or when the app
+// is no longer in the running apps.
+func (ts *TomcatManager) Watch(ctx context.Context, dirs SyncDirs, debounce time.Duration, afterSync func() error) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Watch : %w", err)
+	}
+	defer watcher.Close()
+
+	sourcePath := dirs.Source
+	if err = addWatchDirs(watcher, sourcePath); err != nil {
+		return fmt.Errorf("Watch : %w", err)
+	}
+	slog.Info("Watching the files, ctrl+c to stop", "sourcePath", sourcePath)
+
+	changed := make(map[string]bool)
+	debounceTimer := time.NewTimer(debounce)
+	debounceTimer.Stop()
+	runningTicker := time.NewTicker(watchRunningInterval)
+	defer runningTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Watch stopped")
+			return nil
+
+		case <-runningTicker.C:
+			props, err := LoadTomcatProps(ts.TomcatPaths.CliBasePath)
+			if err != nil {
+				return fmt.Errorf("Watch : %w", err)
+			}
+			if _, found := findTomcat(props.RunningTomcats, ts.TomcatPaths.AppTomcatName); !found {
+				slog.Info("The app is no longer running, watch stopped", "app", ts.TomcatPaths.AppTomcatName)
+				return nil
+			}
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			info, err := os.Stat(event.Name)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				// a new folder, watch it and sync what is already inside
+				if err = addWatchDirs(watcher, event.Name); err != nil {
+					slog.Warn("Error watching the folder", "folder", event.Name, "error", err)
+				}
+				addDirFiles(changed, sourcePath, event.Name)
+			} else {
+				addChangedFile(changed, sourcePath, event.Name)
+			}
+			debounceTimer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("Watch error", "error", err)
+
+		case <-debounceTimer.C:
+			if syncChanged(dirs, changed) && afterSync != nil {
+				if err := afterSync(); err != nil {
+					slog.Error("Error after the sync", "error", err)
+				}
+			}
+			clear(changed)
+		}
+	}
+}
+
+// syncChanged copies the included changed files, it returns true when something was copied.
+func syncChanged(dirs SyncDirs, changed map[string]bool) bool {
+	var files []string
+	for _, rel := range GetOrderedKeys(changed) {
+		if dirs.Include(rel) {
+			files = append(files, rel)
+		}
+	}
+	if len(files) == 0 {
+		return false
+	}
+	start := time.Now()
+	if err := CopyFiles(dirs.Source, dirs.Dest, files); err != nil {
+		slog.Error("Error syncing the files", "error", err)
+		return false
+	}
+	for _, rel := range files {
+		slog.Info("synced", "file", rel)
+	}
+	slog.Info("files synced", "files", len(files), "duration", time.Since(start).String())
+	return true
+}
+
+// addWatchDirs watches root and its subfolders, fsnotify is not recursive.
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+func addDirFiles(changed map[string]bool, sourcePath, dir string) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			addChangedFile(changed, sourcePath, p)
+		}
+		return nil
+	})
+}
+
+func addChangedFile(changed map[string]bool, sourcePath, file string) {
+	rel, err := filepath.Rel(sourcePath, file)
+	if err != nil {
+		return
+	}
+	changed[filepath.ToSlash(rel)] = true
+}
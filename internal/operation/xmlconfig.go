@@ -0,0 +1,345 @@
+package operation
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+const defaultDatasourceType = "javax.sql.DataSource"
+
+// AppContextFile is the context file of the app in conf/Catalina/localhost.
+func (ts *TomcatManager) AppContextFile() string {
+	return filepath.Join(ts.TomcatPaths.CatalinaLocalhost, ts.TomcatConfig.AppConfig.ContextFileName+".xml")
+}
+
+// ParseNamingSnippet parses a snippet of <Resource> and <ResourceLink> elements, like the ones of .db-resources.yaml.
+func ParseNamingSnippet(snippet string) ([]model.Resource, []model.ResourceLink, error) {
+	var holder struct {
+		Resources     []model.Resource     `xml:"Resource"`
+		ResourceLinks []model.ResourceLink `xml:"ResourceLink"`
+		Other         []model.AnyElement   `xml:",any"`
+	}
+	if err := xml.Unmarshal([]byte("<snippet>"+snippet+"</snippet>"), &holder); err != nil {
+		return nil, nil, fmt.Errorf("ParseNamingSnippet : %w", err)
+	}
+	if len(holder.Other) > 0 {
+		return nil, nil, fmt.Errorf("ParseNamingSnippet : unexpected element <%s>, only Resource and ResourceLink are allowed",
+			holder.Other[0].XMLName.Local)
+	}
+	for _, resource := range holder.Resources {
+		if err := validateResource(resource); err != nil {
+			return nil, nil, fmt.Errorf("ParseNamingSnippet : %w", err)
+		}
+	}
+	for _, link := range holder.ResourceLinks {
+		if err := validateResourceLink(link); err != nil {
+			return nil, nil, fmt.Errorf("ParseNamingSnippet : %w", err)
+		}
+	}
+	return holder.Resources, holder.ResourceLinks, nil
+}
+
+// ApplyXmlConfig edits the rendered server.xml and app context through the xml model:
+// it sets the ports of the current tomcat, merges the db resources by name and validates the result.
+func (ts *TomcatManager) ApplyXmlConfig(data model.TemplateData) error {
+	dbResources, ctxResources, ctxLinks, err := ts.namingResources(data)
+	if err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %w", err)
+	}
+
+	var server model.Server
+	if err = loadXml(ts.TomcatPaths.ServerXml, &server); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %w", err)
+	}
+	setServerPorts(&server, ts.TomcatProps.CurrentTomcat)
+	if server.GlobalNamingResources == nil {
+		server.GlobalNamingResources = &model.GlobalNamingResources{}
+	}
+	server.GlobalNamingResources.Resources = mergeResources(server.GlobalNamingResources.Resources, dbResources)
+	if err = validateServer(server); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %s: %w", ts.TomcatPaths.ServerXml, err)
+	}
+
+	var context model.Context
+	if err = loadXml(ts.AppContextFile(), &context); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %w", err)
+	}
+	context.Resources = mergeResources(context.Resources, ctxResources)
+	context.ResourceLinks = mergeResourceLinks(context.ResourceLinks, ctxLinks)
+	if err = validateContext(context, server.GlobalNamingResources.Resources); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %s: %w", ts.AppContextFile(), err)
+	}
+
+	if err = saveXml(ts.TomcatPaths.ServerXml, server); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %w", err)
+	}
+	if err = saveXml(ts.AppContextFile(), context); err != nil {
+		return fmt.Errorf("ApplyXmlConfig : %w", err)
+	}
+	return nil
+}
+
+// DatasourceResources generates, for the settings of env, the global Resource and the ResourceLink
+// of the app context of every datasource.
+func DatasourceResources(datasources []model.Datasource, env string) ([]model.Resource, []model.ResourceLink, error) {
+	resources := make([]model.Resource, 0, len(datasources))
+	links := make([]model.ResourceLink, 0, len(datasources))
+	for _, ds := range datasources {
+		settings := ds.ForEnv(env)
+		if ds.Name == "" || settings.Driver == "" || settings.Url == "" {
+			return nil, nil, fmt.Errorf("DatasourceResources : datasource %q of env %s: name, driver and url are required", ds.Name, env)
+		}
+		dsType := ds.Type
+		if dsType == "" {
+			dsType = defaultDatasourceType
+		}
+
+		resource := model.Resource{Name: ds.Name, Auth: "Container", Type: dsType}
+		addAttr := func(name, value string) {
+			if value != "" {
+				resource.Attrs = append(resource.Attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+			}
+		}
+		addAttr("driverClassName", settings.Driver)
+		addAttr("url", settings.Url)
+		addAttr("username", settings.User)
+		addAttr("password", settings.Password)
+		for _, key := range GetOrderedKeys(settings.Pool) {
+			addAttr(key, settings.Pool[key])
+		}
+
+		resources = append(resources, resource)
+		links = append(links, model.ResourceLink{Name: ds.Name, Global: ds.Name, Type: dsType})
+	}
+	return resources, links, nil
+}
+
+// DbUrls returns the url of every db resource of the env, by resource name, with the passwords masked.
+func (ts *TomcatManager) DbUrls(data model.TemplateData) (map[string]string, error) {
+	dbResources, ctxResources, _, err := ts.namingResources(data)
+	if err != nil {
+		return nil, fmt.Errorf("DbUrls : %w", err)
+	}
+	urls := make(map[string]string)
+	for _, resource := range append(dbResources, ctxResources...) {
+		for _, attr := range resource.Attrs {
+			if attr.Name.Local == "url" {
+				urls[resource.Name] = MaskUrl(attr.Value)
+			}
+		}
+	}
+	return urls, nil
+}
+
+// namingResources returns the global resources, the context resources and the context links of the env,
+// from both the legacy snippets and the structured datasources.
+func (ts *TomcatManager) namingResources(data model.TemplateData) ([]model.Resource, []model.Resource, []model.ResourceLink, error) {
+	dbResources, _, err := ParseNamingSnippet(data.DbResources)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("namingResources : db_resource of env %s not valid: %w", data.Env, err)
+	}
+	ctxResources, ctxLinks, err := ParseNamingSnippet(data.DbContext)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("namingResources : db_context of env %s not valid: %w", data.Env, err)
+	}
+
+	datasources, err := ts.GetDatasources()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("namingResources : %w", err)
+	}
+	dsResources, dsLinks, err := DatasourceResources(datasources, data.Env)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("namingResources : %w", err)
+	}
+	return mergeResources(dbResources, dsResources), ctxResources, mergeResourceLinks(ctxLinks, dsLinks), nil
+}
+
+// setServerPorts sets the shutdown port, the first http connector to the main port and the first ajp one
+// to the connector port. Every connector with a redirect port gets the redirect port.
+func setServerPorts(server *model.Server, tomcat model.Tomcat) {
+	server.Port = strconv.Itoa(tomcat.ServerPort)
+	httpSet, ajpSet := false, false
+	for i := range server.Services {
+		for j := range server.Services[i].Connectors {
+			connector := &server.Services[i].Connectors[j]
+			switch {
+			case strings.Contains(strings.ToUpper(connector.Protocol), "AJP"):
+				if !ajpSet {
+					connector.Port = strconv.Itoa(tomcat.ConnectorPort)
+					ajpSet = true
+				}
+			case !httpSet:
+				connector.Port = strconv.Itoa(tomcat.MainPort)
+				httpSet = true
+			}
+			if connector.RedirectPort != "" {
+				connector.RedirectPort = strconv.Itoa(tomcat.RedirectPort)
+			}
+		}
+	}
+}
+
+func mergeResources(current, toAdd []model.Resource) []model.Resource {
+	result := append([]model.Resource{}, current...)
+	for _, resource := range toAdd {
+		replaced := false
+		for i := range result {
+			if result[i].Name == resource.Name {
+				result[i] = resource
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, resource)
+		}
+	}
+	return result
+}
+
+func mergeResourceLinks(current, toAdd []model.ResourceLink) []model.ResourceLink {
+	result := append([]model.ResourceLink{}, current...)
+	for _, link := range toAdd {
+		replaced := false
+		for i := range result {
+			if result[i].Name == link.Name {
+				result[i] = link
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			result = append(result, link)
+		}
+	}
+	return result
+}
+
+func validateServer(server model.Server) error {
+	usedPorts := make(map[string]string)
+	addPort := func(owner, port string) error {
+		if err := validatePort(owner, port); err != nil {
+			return err
+		}
+		if port == "-1" || strings.Contains(port, "${") {
+			return nil
+		}
+		if other, used := usedPorts[port]; used {
+			return fmt.Errorf("port %s used by both %s and %s", port, other, owner)
+		}
+		usedPorts[port] = owner
+		return nil
+	}
+
+	if err := addPort("Server", server.Port); err != nil {
+		return err
+	}
+	for _, service := range server.Services {
+		for _, connector := range service.Connectors {
+			owner := fmt.Sprintf("Connector %s of Service %s", connector.Protocol, service.Name)
+			if err := addPort(owner, connector.Port); err != nil {
+				return err
+			}
+			if connector.RedirectPort != "" {
+				if err := validatePort(owner+" redirectPort", connector.RedirectPort); err != nil {
+					return err
+				}
+			}
+		}
+		if service.Engine == nil {
+			return fmt.Errorf("Service %s has no Engine", service.Name)
+		}
+	}
+	if server.GlobalNamingResources != nil {
+		names := make(map[string]bool)
+		for _, resource := range server.GlobalNamingResources.Resources {
+			if err := validateResource(resource); err != nil {
+				return err
+			}
+			if names[resource.Name] {
+				return fmt.Errorf("Resource %s defined twice", resource.Name)
+			}
+			names[resource.Name] = true
+		}
+	}
+	return nil
+}
+
+// validateContext checks the resources and that every ResourceLink points to a global resource.
+func validateContext(context model.Context, globalResources []model.Resource) error {
+	for _, resource := range context.Resources {
+		if err := validateResource(resource); err != nil {
+			return err
+		}
+	}
+	for _, link := range context.ResourceLinks {
+		if err := validateResourceLink(link); err != nil {
+			return err
+		}
+		found := false
+		for _, resource := range globalResources {
+			if resource.Name == link.Global {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("ResourceLink %s points to the global resource %s, not defined in server.xml", link.Name, link.Global)
+		}
+	}
+	return nil
+}
+
+func validateResource(resource model.Resource) error {
+	if resource.Name == "" || resource.Type == "" {
+		return fmt.Errorf("Resource %q: name and type are required", resource.Name)
+	}
+	return nil
+}
+
+func validateResourceLink(link model.ResourceLink) error {
+	if link.Name == "" || link.Global == "" || link.Type == "" {
+		return fmt.Errorf("ResourceLink %q: name, global and type are required", link.Name)
+	}
+	return nil
+}
+
+// validatePort accepts a port number, -1 to disable it or a ${property} resolved by tomcat.
+func validatePort(owner, port string) error {
+	if port == "-1" || strings.Contains(port, "${") {
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s: port not valid: %q", owner, port)
+	}
+	return nil
+}
+
+func loadXml(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("loadXml : %w", err)
+	}
+	if err = xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("loadXml : %s: %w", path, err)
+	}
+	return nil
+}
+
+func saveXml(path string, v any) error {
+	data, err := xml.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("saveXml : %w", err)
+	}
+	if err = os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0644); err != nil {
+		return fmt.Errorf("saveXml : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package operation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/nanaki-93/go-tomcat/internal/model"
+)
+
+// xmlTree is an element with its attributes and children, without comments and whitespace.
+type xmlTree struct {
+	Name     string
+	Attrs    []string
+	Text     string
+	Children []*xmlTree
+}
+
+func parseTree(t *testing.T, data []byte) *xmlTree {
+	t.Helper()
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*xmlTree
+	var root *xmlTree
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("parseTree : %v", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			node := &xmlTree{Name: tok.Name.Local}
+			for _, attr := range tok.Attr {
+				node.Attrs = append(node.Attrs, fmt.Sprintf("%s=%s", attr.Name.Local, attr.Value))
+			}
+			sort.Strings(node.Attrs)
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, node)
+			} else {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += strings.TrimSpace(string(tok))
+			}
+		}
+	}
+	return root
+}
+
+func (n *xmlTree) String() string {
+	var b strings.Builder
+	n.write(&b, "")
+	return b.String()
+}
+
+func (n *xmlTree) write(b *strings.Builder, indent string) {
+	fmt.Fprintf(b, "%s<%s %s>%s\n", indent, n.Name, strings.Join(n.Attrs, " "), n.Text)
+	for _, child := range n.Children {
+		child.write(b, indent+"  ")
+	}
+}
+
+func TestServerXmlRoundTrip(t *testing.T) {
+	original, err := os.ReadFile(filepath.Join("testdata", "tomcat9-server.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "server.xml")
+	if err = os.WriteFile(path, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var server model.Server
+	if err = loadXml(path, &server); err != nil {
+		t.Fatal(err)
+	}
+	if err = saveXml(path, server); err != nil {
+		t.Fatal(err)
+	}
+	rewritten, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the elements are compared without their order inside each parent: the model writes
+	// the elements it doesn't know before the ones it knows
+	want, got := parseTree(t, original), parseTree(t, rewritten)
+	sortTree(want)
+	sortTree(got)
+	if want.String() != got.String() {
+		t.Errorf("round trip changed server.xml\nwant:\n%s\ngot:\n%s", want, got)
+	}
+	for _, kept := range []string{"<SSLHostConfig", "<Certificate", "<UpgradeProtocol", "<Executor", "<Valve", "UserDatabaseRealm"} {
+		if !strings.Contains(string(rewritten), kept) {
+			t.Errorf("round trip dropped %s", kept)
+		}
+	}
+}
+
+func sortTree(n *xmlTree) {
+	for _, child := range n.Children {
+		sortTree(child)
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool {
+		return n.Children[i].String() < n.Children[j].String()
+	})
+}
+
+func TestSetServerPorts(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "tomcat9-server.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var server model.Server
+	if err = xml.Unmarshal(data, &server); err != nil {
+		t.Fatal(err)
+	}
+
+	setServerPorts(&server, model.Tomcat{MainPort: 9000, ServerPort: 8000, ConnectorPort: 8100, RedirectPort: 8400})
+
+	if server.Port != "8000" {
+		t.Errorf("server port = %s, want 8000", server.Port)
+	}
+	want := []struct{ port, redirectPort string }{
+		{"9000", "8400"}, // first http connector
+		{"8443", ""},     // other http connectors are kept
+		{"8100", "8400"}, // first ajp connector
+	}
+	connectors := server.Services[0].Connectors
+	if len(connectors) != len(want) {
+		t.Fatalf("got %d connectors, want %d", len(connectors), len(want))
+	}
+	for i, w := range want {
+		if connectors[i].Port != w.port || connectors[i].RedirectPort != w.redirectPort {
+			t.Errorf("connector %d = %s/%s, want %s/%s", i, connectors[i].Port, connectors[i].RedirectPort, w.port, w.redirectPort)
+		}
+	}
+	if err = validateServer(server); err != nil {
+		t.Errorf("validateServer : %v", err)
+	}
+}